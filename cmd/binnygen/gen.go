@@ -0,0 +1,690 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const binnyPath = "github.com/missionMeteora/binny.v2"
+
+// generate type checks the package in dir, ignoring the file skip, and returns the unformatted source
+// with the methods for the named types, or all the struct types in the package if names is empty.
+func generate(dir, skip string, names []string) ([]byte, error) {
+	bp, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, name := range bp.GoFiles {
+		if name == skip {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+
+	conf := types.Config{
+		Importer: importer.ForCompiler(fset, "source", nil),
+		Error:    func(error) {}, // other files might use the methods we're about to generate.
+	}
+	pkg, _ := conf.Check(bp.ImportPath, fset, files, nil)
+
+	tns, err := structTypes(pkg, names)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &generator{pkg: pkg, imports: map[string]string{binnyPath: "binny"}}
+	for _, tn := range tns {
+		if err = g.genType(tn); err != nil {
+			return nil, err
+		}
+	}
+
+	cmd := "binnygen"
+	if len(names) > 0 {
+		cmd += " -type " + strings.Join(names, ",")
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by \"%s\"; DO NOT EDIT.\n\n", cmd)
+	fmt.Fprintf(&out, "package %s\n\nimport (\n", pkg.Name())
+	var std, other []string
+	for path := range g.imports {
+		if strings.Contains(strings.SplitN(path, "/", 2)[0], ".") {
+			other = append(other, path)
+		} else {
+			std = append(std, path)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(other)
+	for _, path := range append(append(std, ""), other...) {
+		switch name := g.imports[path]; {
+		case path == "":
+			out.WriteString("\n")
+		case name != filepath.Base(path):
+			fmt.Fprintf(&out, "\t%s %q\n", name, path)
+		default:
+			fmt.Fprintf(&out, "\t%q\n", path)
+		}
+	}
+	out.WriteString(")\n")
+	out.Write(g.buf.Bytes())
+	return out.Bytes(), nil
+}
+
+// structTypes returns the named types, or all the struct types in the package in source order.
+func structTypes(pkg *types.Package, names []string) ([]*types.TypeName, error) {
+	scope := pkg.Scope()
+	if len(names) == 0 {
+		var tns []*types.TypeName
+		for _, name := range scope.Names() {
+			tn, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || tn.IsAlias() {
+				continue
+			}
+			if _, ok = tn.Type().Underlying().(*types.Struct); ok {
+				tns = append(tns, tn)
+			}
+		}
+		sort.Slice(tns, func(i, j int) bool { return tns[i].Pos() < tns[j].Pos() })
+		return tns, nil
+	}
+
+	tns := make([]*types.TypeName, 0, len(names))
+	for _, name := range names {
+		tn, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok {
+			return nil, fmt.Errorf("type %s not found in %s", name, pkg.Name())
+		}
+		if _, ok = tn.Type().Underlying().(*types.Struct); !ok {
+			return nil, fmt.Errorf("%s is not a struct", name)
+		}
+		tns = append(tns, tn)
+	}
+	return tns, nil
+}
+
+type generator struct {
+	pkg     *types.Package
+	imports map[string]string // path -> name
+	buf     bytes.Buffer
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+func (g *generator) qualifier(p *types.Package) string {
+	if p == g.pkg {
+		return ""
+	}
+	g.imports[p.Path()] = p.Name()
+	return p.Name()
+}
+
+func (g *generator) typeString(t types.Type) string {
+	return types.TypeString(t, g.qualifier)
+}
+
+// fieldCode is the generated code for a single field.
+type fieldCode struct {
+	embedded string // condition for the embedded pointers leading to the field to be non-nil
+	nonZero  string // condition for the field to be non-zero, empty if it's never zero
+	isNil    string // condition for the field to be written as Nil, empty if it can't be nil
+	enc      string // expression that writes the field and returns an error
+	dec      string // statements that read the field and set err
+	zero     string // statements that reset the field to its zero value
+}
+
+// present returns the condition for the field to be written, empty if it always is,
+// usesKeep is true if the condition depends on a keep variable holding EncoderOptions.KeepZeroFields.
+func (c *fieldCode) present(f *field) (cond string, usesKeep bool) {
+	cond = c.nonZero
+	switch {
+	case cond == "false":
+		return
+	case f.keepZero:
+		cond = ""
+	case f.omitEmpty || cond == "":
+	default:
+		cond, usesKeep = paren(cond)+" || keep", true
+	}
+	switch {
+	case c.embedded == "":
+	case cond == "":
+		cond = c.embedded
+	default:
+		cond = c.embedded + " && " + paren(cond)
+	}
+	return
+}
+
+func paren(cond string) string {
+	if strings.Contains(cond, "&&") || strings.Contains(cond, "||") {
+		return "(" + cond + ")"
+	}
+	return cond
+}
+
+func (g *generator) genType(tn *types.TypeName) error {
+	named, ok := tn.Type().(*types.Named)
+	if !ok || named.TypeParams().Len() > 0 {
+		return fmt.Errorf("%s: generic and alias types are not supported", tn.Name())
+	}
+
+	name, fields := tn.Name(), typeFields(named)
+	namesVar := "_" + name + "_binnyFields"
+
+	g.printf("\nvar %s = []string{", namesVar)
+	for i, f := range fields {
+		if i > 0 {
+			g.printf(", ")
+		}
+		g.printf("%q", f.name)
+	}
+	g.printf("}\n")
+
+	if len(fields) == 0 {
+		g.printf("\n// MarshalBinny implements binny.Marshaler.\n")
+		g.printf("func (x *%s) MarshalBinny(enc *binny.Encoder) error {\n", name)
+		g.printf("return enc.EncodeStruct(x, %s, nil, nil)\n}\n", namesVar)
+		g.printf("\n// UnmarshalBinny implements binny.Unmarshaler.\n")
+		g.printf("func (x *%s) UnmarshalBinny(dec *binny.Decoder) error {\n", name)
+		g.printf("return dec.DecodeStruct(x, %s, nil)\n}\n", namesVar)
+		return nil
+	}
+
+	code := make([]fieldCode, len(fields))
+	conds := make([]string, len(fields))
+	useKeep := false
+	for i := range fields {
+		code[i] = g.fieldCode(&fields[i])
+		var usesKeep bool
+		conds[i], usesKeep = code[i].present(&fields[i])
+		useKeep = useKeep || usesKeep
+	}
+
+	g.printf("\n// MarshalBinny implements binny.Marshaler.\n")
+	g.printf("func (x *%s) MarshalBinny(enc *binny.Encoder) error {\n", name)
+	if useKeep {
+		g.printf("keep := enc.Options().KeepZeroFields\n")
+	}
+	g.printf("var set [%d]byte\n", (len(fields)+7)/8)
+	for i, cond := range conds {
+		switch cond {
+		case "false":
+		case "":
+			g.printf("set[%d] |= 1 << %d\n", i/8, i%8)
+		default:
+			g.printf("if %s {\nset[%d] |= 1 << %d\n}\n", cond, i/8, i%8)
+		}
+	}
+	g.printf("return enc.EncodeStruct(x, %s, set[:], func(i int) error {\nswitch i {\n", namesVar)
+	for i, c := range code {
+		if conds[i] == "false" {
+			continue
+		}
+		g.printf("case %d:\n", i)
+		if c.isNil != "" && !fields[i].omitEmpty {
+			g.printf("if %s {\nreturn enc.WriteNil()\n}\n", c.isNil)
+		}
+		g.printf("return %s\n", c.enc)
+	}
+	g.printf("}\nreturn nil\n})\n}\n")
+
+	g.printf("\n// UnmarshalBinny implements binny.Unmarshaler.\n")
+	g.printf("func (x *%s) UnmarshalBinny(dec *binny.Decoder) error {\n", name)
+	g.printf("return dec.DecodeStruct(x, %s, func(i int) (err error) {\n", namesVar)
+	g.printf("var isNil bool\nif isNil, err = dec.ReadNil(); err != nil {\nreturn\n}\n")
+	g.printf("if isNil {\nswitch i {\n")
+	for i, c := range code {
+		g.printf("case %d:\n%s\n", i, c.zero)
+	}
+	g.printf("}\nreturn\n}\nswitch i {\n")
+	for i, c := range code {
+		g.printf("case %d:\n%s\n", i, c.dec)
+	}
+	g.printf("}\nreturn\n})\n}\n")
+	return nil
+}
+
+// fieldCode mirrors what the reflection encoder and decoder do with the field,
+// nil embedded pointers are skipped when encoding and allocated when decoding.
+func (g *generator) fieldCode(f *field) (c fieldCode) {
+	var (
+		x      = "x"
+		nilChk []string
+		alloc  string
+	)
+	for i, v := range f.path {
+		x += "." + v.Name()
+		if i == len(f.path)-1 {
+			break
+		}
+		if p, ok := unalias(v.Type()).(*types.Pointer); ok {
+			nilChk = append(nilChk, x+" != nil")
+			alloc += fmt.Sprintf("if %s == nil {\n%s = new(%s)\n}\n", x, x, g.typeString(p.Elem()))
+		}
+	}
+	c.embedded = strings.Join(nilChk, " && ")
+
+	ft := unalias(f.path[len(f.path)-1].Type())
+	if p, ok := ft.(*types.Pointer); ok { // the reflection encoder follows unnamed pointers
+		c = g.valueCode(c, "*"+x, p.Elem(), f.asString)
+		if c.nonZero != "false" {
+			c.nonZero = x + " != nil && " + paren(orTrue(c.nonZero))
+			c.nonZero = strings.TrimSuffix(c.nonZero, " && true")
+		}
+		if c.isNil != "" {
+			c.isNil = x + " == nil || " + c.isNil
+		} else {
+			c.isNil = x + " == nil"
+		}
+		if f.asString && quotable(p.Elem()) {
+			c.dec = fmt.Sprintf("if %s == nil {\n%s = new(%s)\n}\n", x, x, g.typeString(p.Elem())) + c.dec
+		} else { // the pointer itself goes through Encode and Decode so it can be shared, see binny.EncoderOptions.References
+			c.enc = "enc.Encode(" + x + ")"
+			c.dec = "err = dec.Decode(&" + x + ")"
+		}
+	} else {
+		c = g.valueCode(c, x, ft, f.asString)
+	}
+
+	c.zero = alloc + x + " = " + g.zeroValue(ft)
+	c.dec = alloc + c.dec
+	return
+}
+
+func orTrue(cond string) string {
+	if cond == "" {
+		return "true"
+	}
+	return cond
+}
+
+// zeroValue returns the zero value literal for t.
+func (g *generator) zeroValue(t types.Type) string {
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		info := u.Info()
+		switch {
+		case info&types.IsBoolean != 0:
+			return "false"
+		case info&types.IsNumeric != 0:
+			return "0"
+		case info&types.IsString != 0:
+			return `""`
+		}
+	case *types.Struct, *types.Array:
+		return g.typeString(t) + "{}"
+	}
+	return "nil"
+}
+
+// valueCode returns the code for the value of expression x of type t,
+// types without a direct equivalent in the Encoder and Decoder API go through Encode and Decode.
+func (g *generator) valueCode(c fieldCode, x string, t types.Type, asString bool) fieldCode {
+	c.nonZero = nonZero(x, t)
+	switch t.Underlying().(type) {
+	case *types.Slice, *types.Map, *types.Pointer, *types.Interface:
+		c.isNil = x + " == nil"
+	}
+
+	if asString && quotable(t) {
+		return g.quotedCode(c, x, t, t.Underlying().(*types.Basic))
+	}
+
+	if !hasCodecMethods(t) {
+		switch u := t.Underlying().(type) {
+		case *types.Basic:
+			info := u.Info()
+			switch {
+			case info&types.IsBoolean != 0:
+				return g.basicCode(c, x, t, "bool", "Bool", false)
+			case info&types.IsUnsigned != 0:
+				return g.basicCode(c, x, t, "uint64", "Uint", true)
+			case info&types.IsInteger != 0:
+				return g.basicCode(c, x, t, "int64", "Int", true)
+			case u.Kind() == types.Float32:
+				return g.basicCode(c, x, t, "float32", "Float32", false)
+			case u.Kind() == types.Float64:
+				return g.basicCode(c, x, t, "float64", "Float64", false)
+			case u.Kind() == types.Complex64:
+				return g.basicCode(c, x, t, "complex64", "Complex64", false)
+			case u.Kind() == types.Complex128:
+				return g.basicCode(c, x, t, "complex128", "Complex128", false)
+			case u.Kind() == types.String:
+				return g.basicCode(c, x, t, "string", "String", false)
+			}
+		case *types.Slice:
+			if types.Identical(u.Elem(), types.Typ[types.Byte]) {
+				c.enc = "enc.WriteBytes(" + x + ")"
+				c.dec = x + ", err = dec.ReadBytes()"
+				return c
+			}
+		}
+	}
+
+	c.enc = "enc.EncodeElem(&" + x + ")"
+	c.dec = "err = dec.Decode(&" + x + ")"
+	return c
+}
+
+// quotable reports whether the string option applies to t, like isQuotable in binny.
+func quotable(t types.Type) bool {
+	u, ok := t.Underlying().(*types.Basic)
+	return ok && u.Info()&(types.IsBoolean|types.IsInteger|types.IsFloat) != 0
+}
+
+// basicCode fills c with the calls to Write<fn> and Read<fn>, converting x from and to vt when needed.
+func (g *generator) basicCode(c fieldCode, x string, t types.Type, vt, fn string, sized bool) fieldCode {
+	ret := "err"
+	if sized {
+		ret = "_, err"
+	}
+	if ts := g.typeString(t); ts == vt {
+		c.enc = "enc.Write" + fn + "(" + x + ")"
+		c.dec = fmt.Sprintf("%s, %s = dec.Read%s()", x, ret, fn)
+	} else {
+		c.enc = "enc.Write" + fn + "(" + vt + "(" + x + "))"
+		c.dec = fmt.Sprintf("var v %s\nv, %s = dec.Read%s()\n%s = %s(v)", vt, ret, fn, x, ts)
+	}
+	return c
+}
+
+// quotedCode fills c with the code for numbers and bools tagged with the string option.
+func (g *generator) quotedCode(c fieldCode, x string, t types.Type, u *types.Basic) fieldCode {
+	g.imports["strconv"] = "strconv"
+	var format, parse, vt string
+	switch info, bits := u.Info(), basicBits(u.Kind()); {
+	case info&types.IsBoolean != 0:
+		format, parse, vt = "strconv.FormatBool(bool(%s))", "strconv.ParseBool(s)", "bool"
+	case info&types.IsUnsigned != 0:
+		format, parse, vt = "strconv.FormatUint(uint64(%s), 10)", fmt.Sprintf("strconv.ParseUint(s, 10, %d)", bits), "uint64"
+	case info&types.IsInteger != 0:
+		format, parse, vt = "strconv.FormatInt(int64(%s), 10)", fmt.Sprintf("strconv.ParseInt(s, 10, %d)", bits), "int64"
+	default:
+		format, parse, vt = fmt.Sprintf("strconv.FormatFloat(float64(%%s), 'g', -1, %d)", bits), fmt.Sprintf("strconv.ParseFloat(s, %d)", bits), "float64"
+	}
+	c.enc = "enc.WriteString(" + fmt.Sprintf(format, x) + ")"
+	c.dec = fmt.Sprintf("var s string\nif s, err = dec.ReadString(); err != nil {\nreturn\n}\n"+
+		"var v %s\nif v, err = %s; err == nil {\n%s = %s(v)\n}", vt, parse, x, g.typeString(t))
+	return c
+}
+
+// basicBits returns the bit size strconv should use for kind k, 0 for int and uint's.
+func basicBits(k types.BasicKind) int {
+	switch k {
+	case types.Int8, types.Uint8:
+		return 8
+	case types.Int16, types.Uint16:
+		return 16
+	case types.Int32, types.Uint32, types.Float32:
+		return 32
+	case types.Int64, types.Uint64, types.Float64:
+		return 64
+	}
+	return 0
+}
+
+// nonZero returns the condition for x to be written, the same zero values the reflection encoder skips.
+// It returns an empty string for types that are always written and "false" for types that never are.
+func nonZero(x string, t types.Type) string {
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		info := u.Info()
+		switch {
+		case info&types.IsBoolean != 0:
+			return x
+		case info&types.IsNumeric != 0:
+			return x + " != 0"
+		case info&types.IsString != 0:
+			return "len(" + x + ") != 0"
+		}
+		return "false"
+	case *types.Slice, *types.Map, *types.Array:
+		return "len(" + x + ") != 0"
+	case *types.Pointer, *types.Interface:
+		return x + " != nil"
+	case *types.Struct:
+		return ""
+	}
+	return "false" // chan, func
+}
+
+var codecMethods = []string{
+	"MarshalBinny", "UnmarshalBinny",
+	"MarshalBinary", "UnmarshalBinary",
+	"GobEncode", "GobDecode",
+}
+
+// hasCodecMethods reports whether t or *t have any of the methods the Encoder and Decoder look for.
+func hasCodecMethods(t types.Type) bool {
+	ms := types.NewMethodSet(types.NewPointer(t))
+	for _, m := range codecMethods {
+		if ms.Lookup(nil, m) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// field is the go/types version of binny's field, see typeFields in types.go.
+type field struct {
+	name   string
+	index  []int
+	path   []*types.Var // the struct fields leading to this one, including itself
+	tagged bool
+	typ    types.Type
+
+	omitEmpty bool
+	keepZero  bool
+	asString  bool
+}
+
+// typeFields returns the fields the reflection encoder would use for t,
+// it follows typeFields in types.go step by step.
+func typeFields(t types.Type) []field {
+	current := []field{}
+	next := []field{{typ: t}}
+
+	count := map[types.Type]int{}
+	nextCount := map[types.Type]int{}
+
+	visited := map[types.Type]bool{}
+
+	var fields []field
+
+	for len(next) > 0 {
+		current, next = next, current[:0]
+		count, nextCount = nextCount, map[types.Type]int{}
+
+		for i := range current {
+			f := &current[i]
+			if visited[f.typ] {
+				continue
+			}
+			visited[f.typ] = true
+
+			st := f.typ.Underlying().(*types.Struct)
+			for i := 0; i < st.NumFields(); i++ {
+				sf := st.Field(i)
+				if !sf.Exported() && !sf.Embedded() {
+					continue
+				}
+				name, opts, ignore, tagged := getTagValues(sf, st.Tag(i))
+				if ignore {
+					continue
+				}
+				index := make([]int, len(f.index)+1)
+				copy(index, f.index)
+				index[len(f.index)] = i
+				path := make([]*types.Var, len(f.path)+1)
+				copy(path, f.path)
+				path[len(f.path)] = sf
+
+				ft := unalias(sf.Type())
+				if p, ok := ft.(*types.Pointer); ok {
+					ft = unalias(p.Elem())
+				}
+
+				inline := opts.inline || sf.Embedded() && name == ""
+				if _, isStruct := ft.Underlying().(*types.Struct); !inline || !isStruct {
+					if name == "" {
+						name = sf.Name()
+					}
+					fields = append(fields, field{
+						name:      name,
+						index:     index,
+						path:      path,
+						typ:       ft,
+						tagged:    tagged,
+						omitEmpty: opts.omitEmpty,
+						keepZero:  opts.keepZero,
+						asString:  opts.asString,
+					})
+					if count[f.typ] > 1 {
+						fields = append(fields, fields[len(fields)-1])
+					}
+					continue
+				}
+
+				nextCount[ft]++
+				if nextCount[ft] == 1 {
+					next = append(next, field{name: sf.Name(), index: index, path: path, typ: ft})
+				}
+			}
+		}
+	}
+
+	sort.Slice(fields, func(i, j int) bool { return byName(fields[i], fields[j]) })
+
+	out := fields[:0]
+	for advance, i := 0, 0; i < len(fields); i += advance {
+		fi := fields[i]
+		name := fi.name
+		for advance = 1; i+advance < len(fields); advance++ {
+			if fields[i+advance].name != name {
+				break
+			}
+		}
+		if advance == 1 {
+			out = append(out, fi)
+			continue
+		}
+		if dominant, ok := dominantField(fields[i : i+advance]); ok {
+			out = append(out, dominant)
+		}
+	}
+
+	fields = out
+	sort.Slice(fields, func(i, j int) bool { return lessIndex(fields[i].index, fields[j].index) })
+	return fields
+}
+
+func dominantField(fields []field) (field, bool) {
+	length := len(fields[0].index)
+	tagged := -1
+	for i, f := range fields {
+		if len(f.index) > length {
+			fields = fields[:i]
+			break
+		}
+		if f.tagged {
+			if tagged >= 0 {
+				return field{}, false
+			}
+			tagged = i
+		}
+	}
+	if tagged >= 0 {
+		return fields[tagged], true
+	}
+	if len(fields) > 1 {
+		return field{}, false
+	}
+	return fields[0], true
+}
+
+func byName(xi, xj field) bool {
+	if xi.name != xj.name {
+		return xi.name < xj.name
+	}
+	if xi.tagged != xj.tagged {
+		return xi.tagged
+	}
+	if len(xi.index) != len(xj.index) {
+		return len(xi.index) < len(xj.index)
+	}
+	return lessIndex(xi.index, xj.index)
+}
+
+func lessIndex(a, b []int) bool {
+	for k, ak := range a {
+		if k >= len(b) {
+			return false
+		}
+		if ak != b[k] {
+			return ak < b[k]
+		}
+	}
+	return len(a) < len(b)
+}
+
+// tagOptions are the options after the name in a binny tag, see tagOptions in types.go.
+type tagOptions struct {
+	omitEmpty bool
+	keepZero  bool
+	inline    bool
+	asString  bool
+}
+
+func parseTagOptions(s string) (opts tagOptions) {
+	for _, o := range strings.Split(s, ",") {
+		switch o {
+		case "omitempty":
+			opts.omitEmpty = true
+		case "keepzero", "alwaysemit":
+			opts.keepZero = true
+		case "inline":
+			opts.inline = true
+		case "string":
+			opts.asString = true
+		}
+	}
+	return
+}
+
+func getTagValues(sf *types.Var, tag string) (name string, opts tagOptions, ignore, tagged bool) {
+	v := reflect.StructTag(tag).Get("binny")
+	if v == "-" {
+		return "", opts, true, true
+	}
+	if i := strings.IndexByte(v, ','); i > -1 {
+		v, opts = v[:i], parseTagOptions(v[i+1:])
+	}
+	if len(v) > 0 {
+		return v, opts, false, true
+	}
+	if sf.Embedded() || opts.inline {
+		return "", opts, false, false
+	}
+	return sf.Name(), opts, false, false
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"go/format"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestGolden makes sure internal/gentest/binny_gen.go is what the generator currently outputs,
+// run go generate in internal/gentest to update it.
+func TestGolden(t *testing.T) {
+	dir := filepath.Join("internal", "gentest")
+	src, err := generate(dir, "binny_gen.go", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src, err = format.Source(src); err != nil {
+		t.Fatal(err)
+	}
+	golden, err := os.ReadFile(filepath.Join(dir, "binny_gen.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(src, golden) {
+		t.Fatalf("%s/binny_gen.go is out of date, run go generate", dir)
+	}
+}
+
+func TestTypes(t *testing.T) {
+	dir := filepath.Join("internal", "gentest")
+	if _, err := generate(dir, "binny_gen.go", []string{"Kind"}); err == nil {
+		t.Fatal("expected an error for a non-struct type")
+	}
+	if _, err := generate(dir, "binny_gen.go", []string{"Nope"}); err == nil {
+		t.Fatal("expected an error for a missing type")
+	}
+	src, err := generate(dir, "binny_gen.go", []string{"Embedded"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(src, []byte("func (x *Embedded) MarshalBinny")) || bytes.Contains(src, []byte("func (x *Basic)")) {
+		t.Fatalf("unexpected output:\n%s", src)
+	}
+}
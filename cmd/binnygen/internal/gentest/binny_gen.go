@@ -0,0 +1,898 @@
+// Code generated by "binnygen"; DO NOT EDIT.
+
+package gentest
+
+import (
+	"strconv"
+	"time"
+
+	binny "github.com/missionMeteora/binny.v2"
+)
+
+var _Basic_binnyFields = []string{"Str", "I", "I8", "I16", "I32", "I64", "U", "U8", "U16", "U32", "U64", "Uptr", "F32", "F64", "C64", "C128", "B", "Bytes", "renamed", "Kind", "Dur"}
+
+// MarshalBinny implements binny.Marshaler.
+func (x *Basic) MarshalBinny(enc *binny.Encoder) error {
+	keep := enc.Options().KeepZeroFields
+	var set [3]byte
+	if len(x.Str) != 0 || keep {
+		set[0] |= 1 << 0
+	}
+	if x.I != 0 || keep {
+		set[0] |= 1 << 1
+	}
+	if x.I8 != 0 || keep {
+		set[0] |= 1 << 2
+	}
+	if x.I16 != 0 || keep {
+		set[0] |= 1 << 3
+	}
+	if x.I32 != 0 || keep {
+		set[0] |= 1 << 4
+	}
+	if x.I64 != 0 || keep {
+		set[0] |= 1 << 5
+	}
+	if x.U != 0 || keep {
+		set[0] |= 1 << 6
+	}
+	if x.U8 != 0 || keep {
+		set[0] |= 1 << 7
+	}
+	if x.U16 != 0 || keep {
+		set[1] |= 1 << 0
+	}
+	if x.U32 != 0 || keep {
+		set[1] |= 1 << 1
+	}
+	if x.U64 != 0 || keep {
+		set[1] |= 1 << 2
+	}
+	if x.Uptr != 0 || keep {
+		set[1] |= 1 << 3
+	}
+	if x.F32 != 0 || keep {
+		set[1] |= 1 << 4
+	}
+	if x.F64 != 0 || keep {
+		set[1] |= 1 << 5
+	}
+	if x.C64 != 0 || keep {
+		set[1] |= 1 << 6
+	}
+	if x.C128 != 0 || keep {
+		set[1] |= 1 << 7
+	}
+	if x.B || keep {
+		set[2] |= 1 << 0
+	}
+	if len(x.Bytes) != 0 || keep {
+		set[2] |= 1 << 1
+	}
+	if x.Renamed != 0 || keep {
+		set[2] |= 1 << 2
+	}
+	if x.Kind != 0 || keep {
+		set[2] |= 1 << 3
+	}
+	if x.Dur != 0 || keep {
+		set[2] |= 1 << 4
+	}
+	return enc.EncodeStruct(x, _Basic_binnyFields, set[:], func(i int) error {
+		switch i {
+		case 0:
+			return enc.WriteString(x.Str)
+		case 1:
+			return enc.WriteInt(int64(x.I))
+		case 2:
+			return enc.WriteInt(int64(x.I8))
+		case 3:
+			return enc.WriteInt(int64(x.I16))
+		case 4:
+			return enc.WriteInt(int64(x.I32))
+		case 5:
+			return enc.WriteInt(x.I64)
+		case 6:
+			return enc.WriteUint(uint64(x.U))
+		case 7:
+			return enc.WriteUint(uint64(x.U8))
+		case 8:
+			return enc.WriteUint(uint64(x.U16))
+		case 9:
+			return enc.WriteUint(uint64(x.U32))
+		case 10:
+			return enc.WriteUint(x.U64)
+		case 11:
+			return enc.WriteUint(uint64(x.Uptr))
+		case 12:
+			return enc.WriteFloat32(x.F32)
+		case 13:
+			return enc.WriteFloat64(x.F64)
+		case 14:
+			return enc.WriteComplex64(x.C64)
+		case 15:
+			return enc.WriteComplex128(x.C128)
+		case 16:
+			return enc.WriteBool(x.B)
+		case 17:
+			if x.Bytes == nil {
+				return enc.WriteNil()
+			}
+			return enc.WriteBytes(x.Bytes)
+		case 18:
+			return enc.WriteInt(int64(x.Renamed))
+		case 19:
+			return enc.WriteUint(uint64(x.Kind))
+		case 20:
+			return enc.WriteInt(int64(x.Dur))
+		}
+		return nil
+	})
+}
+
+// UnmarshalBinny implements binny.Unmarshaler.
+func (x *Basic) UnmarshalBinny(dec *binny.Decoder) error {
+	return dec.DecodeStruct(x, _Basic_binnyFields, func(i int) (err error) {
+		var isNil bool
+		if isNil, err = dec.ReadNil(); err != nil {
+			return
+		}
+		if isNil {
+			switch i {
+			case 0:
+				x.Str = ""
+			case 1:
+				x.I = 0
+			case 2:
+				x.I8 = 0
+			case 3:
+				x.I16 = 0
+			case 4:
+				x.I32 = 0
+			case 5:
+				x.I64 = 0
+			case 6:
+				x.U = 0
+			case 7:
+				x.U8 = 0
+			case 8:
+				x.U16 = 0
+			case 9:
+				x.U32 = 0
+			case 10:
+				x.U64 = 0
+			case 11:
+				x.Uptr = 0
+			case 12:
+				x.F32 = 0
+			case 13:
+				x.F64 = 0
+			case 14:
+				x.C64 = 0
+			case 15:
+				x.C128 = 0
+			case 16:
+				x.B = false
+			case 17:
+				x.Bytes = nil
+			case 18:
+				x.Renamed = 0
+			case 19:
+				x.Kind = 0
+			case 20:
+				x.Dur = 0
+			}
+			return
+		}
+		switch i {
+		case 0:
+			x.Str, err = dec.ReadString()
+		case 1:
+			var v int64
+			v, _, err = dec.ReadInt()
+			x.I = int(v)
+		case 2:
+			var v int64
+			v, _, err = dec.ReadInt()
+			x.I8 = int8(v)
+		case 3:
+			var v int64
+			v, _, err = dec.ReadInt()
+			x.I16 = int16(v)
+		case 4:
+			var v int64
+			v, _, err = dec.ReadInt()
+			x.I32 = int32(v)
+		case 5:
+			x.I64, _, err = dec.ReadInt()
+		case 6:
+			var v uint64
+			v, _, err = dec.ReadUint()
+			x.U = uint(v)
+		case 7:
+			var v uint64
+			v, _, err = dec.ReadUint()
+			x.U8 = uint8(v)
+		case 8:
+			var v uint64
+			v, _, err = dec.ReadUint()
+			x.U16 = uint16(v)
+		case 9:
+			var v uint64
+			v, _, err = dec.ReadUint()
+			x.U32 = uint32(v)
+		case 10:
+			x.U64, _, err = dec.ReadUint()
+		case 11:
+			var v uint64
+			v, _, err = dec.ReadUint()
+			x.Uptr = uintptr(v)
+		case 12:
+			x.F32, err = dec.ReadFloat32()
+		case 13:
+			x.F64, err = dec.ReadFloat64()
+		case 14:
+			x.C64, err = dec.ReadComplex64()
+		case 15:
+			x.C128, err = dec.ReadComplex128()
+		case 16:
+			x.B, err = dec.ReadBool()
+		case 17:
+			x.Bytes, err = dec.ReadBytes()
+		case 18:
+			var v int64
+			v, _, err = dec.ReadInt()
+			x.Renamed = int(v)
+		case 19:
+			var v uint64
+			v, _, err = dec.ReadUint()
+			x.Kind = Kind(v)
+		case 20:
+			var v int64
+			v, _, err = dec.ReadInt()
+			x.Dur = time.Duration(v)
+		}
+		return
+	})
+}
+
+var _Nested_binnyFields = []string{"Basic", "pb", "Basics", "Map", "Arr", "Iface", "Ints", "Big", "When", "Empty", "Fn", "Ch", "Strings"}
+
+// MarshalBinny implements binny.Marshaler.
+func (x *Nested) MarshalBinny(enc *binny.Encoder) error {
+	keep := enc.Options().KeepZeroFields
+	var set [2]byte
+	set[0] |= 1 << 0
+	if x.PBasic != nil || keep {
+		set[0] |= 1 << 1
+	}
+	if len(x.Basics) != 0 || keep {
+		set[0] |= 1 << 2
+	}
+	if len(x.Map) != 0 || keep {
+		set[0] |= 1 << 3
+	}
+	if len(x.Arr) != 0 || keep {
+		set[0] |= 1 << 4
+	}
+	if x.Iface != nil || keep {
+		set[0] |= 1 << 5
+	}
+	if len(x.Ints) != 0 || keep {
+		set[0] |= 1 << 6
+	}
+	if x.Big != nil || keep {
+		set[0] |= 1 << 7
+	}
+	set[1] |= 1 << 0
+	set[1] |= 1 << 1
+	if len(x.Strings) != 0 || keep {
+		set[1] |= 1 << 4
+	}
+	return enc.EncodeStruct(x, _Nested_binnyFields, set[:], func(i int) error {
+		switch i {
+		case 0:
+			return enc.EncodeElem(&x.Basic)
+		case 1:
+			if x.PBasic == nil {
+				return enc.WriteNil()
+			}
+			return enc.Encode(x.PBasic)
+		case 2:
+			if x.Basics == nil {
+				return enc.WriteNil()
+			}
+			return enc.EncodeElem(&x.Basics)
+		case 3:
+			if x.Map == nil {
+				return enc.WriteNil()
+			}
+			return enc.EncodeElem(&x.Map)
+		case 4:
+			return enc.EncodeElem(&x.Arr)
+		case 5:
+			if x.Iface == nil {
+				return enc.WriteNil()
+			}
+			return enc.EncodeElem(&x.Iface)
+		case 6:
+			if x.Ints == nil {
+				return enc.WriteNil()
+			}
+			return enc.EncodeElem(&x.Ints)
+		case 7:
+			if x.Big == nil {
+				return enc.WriteNil()
+			}
+			return enc.Encode(x.Big)
+		case 8:
+			return enc.EncodeElem(&x.When)
+		case 9:
+			return enc.EncodeElem(&x.Empty)
+		case 12:
+			if x.Strings == nil {
+				return enc.WriteNil()
+			}
+			return enc.EncodeElem(&x.Strings)
+		}
+		return nil
+	})
+}
+
+// UnmarshalBinny implements binny.Unmarshaler.
+func (x *Nested) UnmarshalBinny(dec *binny.Decoder) error {
+	return dec.DecodeStruct(x, _Nested_binnyFields, func(i int) (err error) {
+		var isNil bool
+		if isNil, err = dec.ReadNil(); err != nil {
+			return
+		}
+		if isNil {
+			switch i {
+			case 0:
+				x.Basic = Basic{}
+			case 1:
+				x.PBasic = nil
+			case 2:
+				x.Basics = nil
+			case 3:
+				x.Map = nil
+			case 4:
+				x.Arr = [2]uint16{}
+			case 5:
+				x.Iface = nil
+			case 6:
+				x.Ints = nil
+			case 7:
+				x.Big = nil
+			case 8:
+				x.When = time.Time{}
+			case 9:
+				x.Empty = Empty{}
+			case 10:
+				x.Fn = nil
+			case 11:
+				x.Ch = nil
+			case 12:
+				x.Strings = nil
+			}
+			return
+		}
+		switch i {
+		case 0:
+			err = dec.Decode(&x.Basic)
+		case 1:
+			err = dec.Decode(&x.PBasic)
+		case 2:
+			err = dec.Decode(&x.Basics)
+		case 3:
+			err = dec.Decode(&x.Map)
+		case 4:
+			err = dec.Decode(&x.Arr)
+		case 5:
+			err = dec.Decode(&x.Iface)
+		case 6:
+			err = dec.Decode(&x.Ints)
+		case 7:
+			err = dec.Decode(&x.Big)
+		case 8:
+			err = dec.Decode(&x.When)
+		case 9:
+			err = dec.Decode(&x.Empty)
+		case 10:
+			err = dec.Decode(&x.Fn)
+		case 11:
+			err = dec.Decode(&x.Ch)
+		case 12:
+			err = dec.Decode(&x.Strings)
+		}
+		return
+	})
+}
+
+var _Embedded_binnyFields = []string{"ID", "name"}
+
+// MarshalBinny implements binny.Marshaler.
+func (x *Embedded) MarshalBinny(enc *binny.Encoder) error {
+	keep := enc.Options().KeepZeroFields
+	var set [1]byte
+	if x.ID != 0 || keep {
+		set[0] |= 1 << 0
+	}
+	if len(x.Name) != 0 || keep {
+		set[0] |= 1 << 1
+	}
+	return enc.EncodeStruct(x, _Embedded_binnyFields, set[:], func(i int) error {
+		switch i {
+		case 0:
+			return enc.WriteInt(int64(x.ID))
+		case 1:
+			return enc.WriteString(x.Name)
+		}
+		return nil
+	})
+}
+
+// UnmarshalBinny implements binny.Unmarshaler.
+func (x *Embedded) UnmarshalBinny(dec *binny.Decoder) error {
+	return dec.DecodeStruct(x, _Embedded_binnyFields, func(i int) (err error) {
+		var isNil bool
+		if isNil, err = dec.ReadNil(); err != nil {
+			return
+		}
+		if isNil {
+			switch i {
+			case 0:
+				x.ID = 0
+			case 1:
+				x.Name = ""
+			}
+			return
+		}
+		switch i {
+		case 0:
+			var v int64
+			v, _, err = dec.ReadInt()
+			x.ID = int(v)
+		case 1:
+			x.Name, err = dec.ReadString()
+		}
+		return
+	})
+}
+
+var _Inner_binnyFields = []string{"ID", "X", "Y", "Inner"}
+
+// MarshalBinny implements binny.Marshaler.
+func (x *Inner) MarshalBinny(enc *binny.Encoder) error {
+	keep := enc.Options().KeepZeroFields
+	var set [1]byte
+	if x.ID != 0 || keep {
+		set[0] |= 1 << 0
+	}
+	if x.X != 0 || keep {
+		set[0] |= 1 << 1
+	}
+	if x.Y != 0 || keep {
+		set[0] |= 1 << 2
+	}
+	if len(x.Inner) != 0 || keep {
+		set[0] |= 1 << 3
+	}
+	return enc.EncodeStruct(x, _Inner_binnyFields, set[:], func(i int) error {
+		switch i {
+		case 0:
+			return enc.WriteInt(int64(x.ID))
+		case 1:
+			return enc.WriteFloat64(x.X)
+		case 2:
+			return enc.WriteFloat64(x.Y)
+		case 3:
+			return enc.WriteString(x.Inner)
+		}
+		return nil
+	})
+}
+
+// UnmarshalBinny implements binny.Unmarshaler.
+func (x *Inner) UnmarshalBinny(dec *binny.Decoder) error {
+	return dec.DecodeStruct(x, _Inner_binnyFields, func(i int) (err error) {
+		var isNil bool
+		if isNil, err = dec.ReadNil(); err != nil {
+			return
+		}
+		if isNil {
+			switch i {
+			case 0:
+				x.ID = 0
+			case 1:
+				x.X = 0
+			case 2:
+				x.Y = 0
+			case 3:
+				x.Inner = ""
+			}
+			return
+		}
+		switch i {
+		case 0:
+			var v int64
+			v, _, err = dec.ReadInt()
+			x.ID = int(v)
+		case 1:
+			x.X, err = dec.ReadFloat64()
+		case 2:
+			x.Y, err = dec.ReadFloat64()
+		case 3:
+			x.Inner, err = dec.ReadString()
+		}
+		return
+	})
+}
+
+var _Promoted_binnyFields = []string{"name", "X", "Y", "Inner", "Name", "Other"}
+
+// MarshalBinny implements binny.Marshaler.
+func (x *Promoted) MarshalBinny(enc *binny.Encoder) error {
+	keep := enc.Options().KeepZeroFields
+	var set [1]byte
+	if len(x.Embedded.Name) != 0 || keep {
+		set[0] |= 1 << 0
+	}
+	if x.Inner != nil && (x.Inner.X != 0 || keep) {
+		set[0] |= 1 << 1
+	}
+	if x.Inner != nil && (x.Inner.Y != 0 || keep) {
+		set[0] |= 1 << 2
+	}
+	if x.Inner != nil && (len(x.Inner.Inner) != 0 || keep) {
+		set[0] |= 1 << 3
+	}
+	if len(x.Name) != 0 || keep {
+		set[0] |= 1 << 4
+	}
+	if len(x.Other) != 0 || keep {
+		set[0] |= 1 << 5
+	}
+	return enc.EncodeStruct(x, _Promoted_binnyFields, set[:], func(i int) error {
+		switch i {
+		case 0:
+			return enc.WriteString(x.Embedded.Name)
+		case 1:
+			return enc.WriteFloat64(x.Inner.X)
+		case 2:
+			return enc.WriteFloat64(x.Inner.Y)
+		case 3:
+			return enc.WriteString(x.Inner.Inner)
+		case 4:
+			return enc.WriteString(x.Name)
+		case 5:
+			return enc.WriteString(x.Other)
+		}
+		return nil
+	})
+}
+
+// UnmarshalBinny implements binny.Unmarshaler.
+func (x *Promoted) UnmarshalBinny(dec *binny.Decoder) error {
+	return dec.DecodeStruct(x, _Promoted_binnyFields, func(i int) (err error) {
+		var isNil bool
+		if isNil, err = dec.ReadNil(); err != nil {
+			return
+		}
+		if isNil {
+			switch i {
+			case 0:
+				x.Embedded.Name = ""
+			case 1:
+				if x.Inner == nil {
+					x.Inner = new(Inner)
+				}
+				x.Inner.X = 0
+			case 2:
+				if x.Inner == nil {
+					x.Inner = new(Inner)
+				}
+				x.Inner.Y = 0
+			case 3:
+				if x.Inner == nil {
+					x.Inner = new(Inner)
+				}
+				x.Inner.Inner = ""
+			case 4:
+				x.Name = ""
+			case 5:
+				x.Other = ""
+			}
+			return
+		}
+		switch i {
+		case 0:
+			x.Embedded.Name, err = dec.ReadString()
+		case 1:
+			if x.Inner == nil {
+				x.Inner = new(Inner)
+			}
+			x.Inner.X, err = dec.ReadFloat64()
+		case 2:
+			if x.Inner == nil {
+				x.Inner = new(Inner)
+			}
+			x.Inner.Y, err = dec.ReadFloat64()
+		case 3:
+			if x.Inner == nil {
+				x.Inner = new(Inner)
+			}
+			x.Inner.Inner, err = dec.ReadString()
+		case 4:
+			x.Name, err = dec.ReadString()
+		case 5:
+			x.Other, err = dec.ReadString()
+		}
+		return
+	})
+}
+
+var _Tagged_binnyFields = []string{"omit", "keep", "Ints", "Map", "Ptr", "Iface", "ID", "name", "Num", "Float", "Ok", "Kind"}
+
+// MarshalBinny implements binny.Marshaler.
+func (x *Tagged) MarshalBinny(enc *binny.Encoder) error {
+	keep := enc.Options().KeepZeroFields
+	var set [2]byte
+	if x.Omit != 0 {
+		set[0] |= 1 << 0
+	}
+	set[0] |= 1 << 1
+	set[0] |= 1 << 2
+	if len(x.Map) != 0 || keep {
+		set[0] |= 1 << 3
+	}
+	if x.Ptr != nil || keep {
+		set[0] |= 1 << 4
+	}
+	if x.Iface != nil || keep {
+		set[0] |= 1 << 5
+	}
+	if x.Pt.ID != 0 || keep {
+		set[0] |= 1 << 6
+	}
+	if len(x.Pt.Name) != 0 || keep {
+		set[0] |= 1 << 7
+	}
+	if x.Num != 0 || keep {
+		set[1] |= 1 << 0
+	}
+	if x.Float != 0 || keep {
+		set[1] |= 1 << 1
+	}
+	if x.Ok || keep {
+		set[1] |= 1 << 2
+	}
+	if (x.Kind != nil && *x.Kind != 0) || keep {
+		set[1] |= 1 << 3
+	}
+	return enc.EncodeStruct(x, _Tagged_binnyFields, set[:], func(i int) error {
+		switch i {
+		case 0:
+			return enc.WriteInt(int64(x.Omit))
+		case 1:
+			return enc.WriteInt(int64(x.Keep))
+		case 2:
+			if x.Ints == nil {
+				return enc.WriteNil()
+			}
+			return enc.EncodeElem(&x.Ints)
+		case 3:
+			if x.Map == nil {
+				return enc.WriteNil()
+			}
+			return enc.EncodeElem(&x.Map)
+		case 4:
+			if x.Ptr == nil {
+				return enc.WriteNil()
+			}
+			return enc.Encode(x.Ptr)
+		case 5:
+			if x.Iface == nil {
+				return enc.WriteNil()
+			}
+			return enc.EncodeElem(&x.Iface)
+		case 6:
+			return enc.WriteInt(int64(x.Pt.ID))
+		case 7:
+			return enc.WriteString(x.Pt.Name)
+		case 8:
+			return enc.WriteString(strconv.FormatInt(int64(x.Num), 10))
+		case 9:
+			return enc.WriteString(strconv.FormatFloat(float64(x.Float), 'g', -1, 32))
+		case 10:
+			return enc.WriteString(strconv.FormatBool(bool(x.Ok)))
+		case 11:
+			if x.Kind == nil {
+				return enc.WriteNil()
+			}
+			return enc.WriteString(strconv.FormatUint(uint64(*x.Kind), 10))
+		}
+		return nil
+	})
+}
+
+// UnmarshalBinny implements binny.Unmarshaler.
+func (x *Tagged) UnmarshalBinny(dec *binny.Decoder) error {
+	return dec.DecodeStruct(x, _Tagged_binnyFields, func(i int) (err error) {
+		var isNil bool
+		if isNil, err = dec.ReadNil(); err != nil {
+			return
+		}
+		if isNil {
+			switch i {
+			case 0:
+				x.Omit = 0
+			case 1:
+				x.Keep = 0
+			case 2:
+				x.Ints = nil
+			case 3:
+				x.Map = nil
+			case 4:
+				x.Ptr = nil
+			case 5:
+				x.Iface = nil
+			case 6:
+				x.Pt.ID = 0
+			case 7:
+				x.Pt.Name = ""
+			case 8:
+				x.Num = 0
+			case 9:
+				x.Float = 0
+			case 10:
+				x.Ok = false
+			case 11:
+				x.Kind = nil
+			}
+			return
+		}
+		switch i {
+		case 0:
+			var v int64
+			v, _, err = dec.ReadInt()
+			x.Omit = int(v)
+		case 1:
+			var v int64
+			v, _, err = dec.ReadInt()
+			x.Keep = int(v)
+		case 2:
+			err = dec.Decode(&x.Ints)
+		case 3:
+			err = dec.Decode(&x.Map)
+		case 4:
+			err = dec.Decode(&x.Ptr)
+		case 5:
+			err = dec.Decode(&x.Iface)
+		case 6:
+			var v int64
+			v, _, err = dec.ReadInt()
+			x.Pt.ID = int(v)
+		case 7:
+			x.Pt.Name, err = dec.ReadString()
+		case 8:
+			var s string
+			if s, err = dec.ReadString(); err != nil {
+				return
+			}
+			var v int64
+			if v, err = strconv.ParseInt(s, 10, 16); err == nil {
+				x.Num = int16(v)
+			}
+		case 9:
+			var s string
+			if s, err = dec.ReadString(); err != nil {
+				return
+			}
+			var v float64
+			if v, err = strconv.ParseFloat(s, 32); err == nil {
+				x.Float = float32(v)
+			}
+		case 10:
+			var s string
+			if s, err = dec.ReadString(); err != nil {
+				return
+			}
+			var v bool
+			if v, err = strconv.ParseBool(s); err == nil {
+				x.Ok = bool(v)
+			}
+		case 11:
+			if x.Kind == nil {
+				x.Kind = new(Kind)
+			}
+			var s string
+			if s, err = dec.ReadString(); err != nil {
+				return
+			}
+			var v uint64
+			if v, err = strconv.ParseUint(s, 10, 8); err == nil {
+				*x.Kind = Kind(v)
+			}
+		}
+		return
+	})
+}
+
+var _Node_binnyFields = []string{"Value", "Next"}
+
+// MarshalBinny implements binny.Marshaler.
+func (x *Node) MarshalBinny(enc *binny.Encoder) error {
+	keep := enc.Options().KeepZeroFields
+	var set [1]byte
+	if x.Value != 0 || keep {
+		set[0] |= 1 << 0
+	}
+	if x.Next != nil || keep {
+		set[0] |= 1 << 1
+	}
+	return enc.EncodeStruct(x, _Node_binnyFields, set[:], func(i int) error {
+		switch i {
+		case 0:
+			return enc.WriteInt(int64(x.Value))
+		case 1:
+			if x.Next == nil {
+				return enc.WriteNil()
+			}
+			return enc.Encode(x.Next)
+		}
+		return nil
+	})
+}
+
+// UnmarshalBinny implements binny.Unmarshaler.
+func (x *Node) UnmarshalBinny(dec *binny.Decoder) error {
+	return dec.DecodeStruct(x, _Node_binnyFields, func(i int) (err error) {
+		var isNil bool
+		if isNil, err = dec.ReadNil(); err != nil {
+			return
+		}
+		if isNil {
+			switch i {
+			case 0:
+				x.Value = 0
+			case 1:
+				x.Next = nil
+			}
+			return
+		}
+		switch i {
+		case 0:
+			var v int64
+			v, _, err = dec.ReadInt()
+			x.Value = int(v)
+		case 1:
+			err = dec.Decode(&x.Next)
+		}
+		return
+	})
+}
+
+var _Empty_binnyFields = []string{}
+
+// MarshalBinny implements binny.Marshaler.
+func (x *Empty) MarshalBinny(enc *binny.Encoder) error {
+	return enc.EncodeStruct(x, _Empty_binnyFields, nil, nil)
+}
+
+// UnmarshalBinny implements binny.Unmarshaler.
+func (x *Empty) UnmarshalBinny(dec *binny.Decoder) error {
+	return dec.DecodeStruct(x, _Empty_binnyFields, nil)
+}
+
+var _Unexported_binnyFields = []string{}
+
+// MarshalBinny implements binny.Marshaler.
+func (x *Unexported) MarshalBinny(enc *binny.Encoder) error {
+	return enc.EncodeStruct(x, _Unexported_binnyFields, nil, nil)
+}
+
+// UnmarshalBinny implements binny.Unmarshaler.
+func (x *Unexported) UnmarshalBinny(dec *binny.Decoder) error {
+	return dec.DecodeStruct(x, _Unexported_binnyFields, nil)
+}
@@ -0,0 +1,193 @@
+package gentest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	binny "github.com/missionMeteora/binny.v2"
+)
+
+// the plain types don't have the generated methods, so they go through reflection.
+type (
+	plainBasic    Basic
+	plainNested   Nested
+	plainPromoted Promoted
+	plainTagged   Tagged
+	plainEmpty    Empty
+)
+
+var basic = Basic{
+	Str: "str", Ignore: "ignored", I: -1, I8: -8, I16: -16, I32: -32, I64: -64,
+	U: 1, U8: 8, U16: 16, U32: 32, U64: 1 << 60, Uptr: 0xff,
+	F32: 3.2, F64: 6.4, C64: 6 + 4i, C128: 12 + 8i,
+	B: true, Bytes: []byte("bytes"), Renamed: 42, Kind: 7, Dur: time.Second,
+}
+
+func TestGenerated(t *testing.T) {
+	nested := Nested{
+		Basic:   basic,
+		PBasic:  &Basic{Str: "ptr"},
+		Basics:  []Basic{{I: 1}, {}, {U: 2}},
+		Map:     map[string]int{"a": 1, "b": 2},
+		Arr:     [2]uint16{1, 0},
+		Iface:   "iface",
+		Ints:    []int{1, 2, 3},
+		Big:     big.NewInt(1 << 40),
+		When:    time.Unix(1234567890, 0).UTC(),
+		Strings: map[int]string{1: "one"},
+	}
+	promoted := Promoted{
+		Embedded: Embedded{ID: 1, Name: "embedded"},
+		Inner:    &Inner{ID: 2, X: 1.5, Inner: "inner"},
+		Name:     "hidden",
+		Other:    "other",
+	}
+
+	kind := Kind(3)
+	tagged := Tagged{
+		Omit: 1, Ints: []int{}, Map: map[string]string{"a": "b"}, Ptr: &Inner{ID: 1}, Iface: int64(5),
+		Pt: Embedded{ID: 2}, Num: -7, Float: 1.5, Ok: true, Kind: &kind,
+	}
+
+	tests := []struct {
+		name     string
+		gen, ref interface{}
+		out      func() (gen, ref interface{})
+	}{
+		{"Basic", &basic, (*plainBasic)(&basic), func() (interface{}, interface{}) { return new(Basic), new(plainBasic) }},
+		{"zero Basic", &Basic{}, &plainBasic{}, func() (interface{}, interface{}) { return new(Basic), new(plainBasic) }},
+		{"Nested", &nested, (*plainNested)(&nested), func() (interface{}, interface{}) { return new(Nested), new(plainNested) }},
+		{"Promoted", &promoted, (*plainPromoted)(&promoted), func() (interface{}, interface{}) { return new(Promoted), new(plainPromoted) }},
+		{"nil embedded", &Promoted{Other: "x"}, &plainPromoted{Other: "x"}, func() (interface{}, interface{}) { return new(Promoted), new(plainPromoted) }},
+		{"Tagged", &tagged, (*plainTagged)(&tagged), func() (interface{}, interface{}) { return new(Tagged), new(plainTagged) }},
+		{"zero Tagged", &Tagged{}, &plainTagged{}, func() (interface{}, interface{}) { return new(Tagged), new(plainTagged) }},
+		{"Empty", &Empty{}, &plainEmpty{}, func() (interface{}, interface{}) { return new(Empty), new(plainEmpty) }},
+	}
+
+	// Canonical so the maps get written in the same order.
+	optsList := []binny.EncoderOptions{
+		{Canonical: true},
+		{Canonical: true, PackStructs: true},
+		{Canonical: true, InternFieldNames: true},
+		{Canonical: true, ByteOrder: binary.BigEndian},
+		{Canonical: true, KeepZeroFields: true},
+		{Canonical: true, KeepZeroFields: true, PackStructs: true},
+		{Canonical: true, References: true},
+		{Canonical: true, References: true, PackStructs: true},
+		{Canonical: true, Compact: true},
+	}
+
+	for _, tt := range tests {
+		for _, opts := range optsList {
+			gb, rb := encode(t, tt.gen, opts), encode(t, tt.ref, opts)
+			if !bytes.Equal(gb, rb) {
+				t.Fatalf("%s %+v: generated and reflection output differ:\nexp: %v\ngot: %v", tt.name, opts, rb, gb)
+			}
+
+			gen, ref := tt.out()
+			dopts := binny.DecoderOptions{ByteOrder: opts.ByteOrder, Compact: opts.Compact}
+			if err := binny.NewDecoderOptions(bytes.NewReader(rb), dopts).Decode(gen); err != nil {
+				t.Fatalf("%s %+v: %v", tt.name, opts, err)
+			}
+			if err := binny.NewDecoderOptions(bytes.NewReader(gb), dopts).Decode(ref); err != nil {
+				t.Fatalf("%s %+v: %v", tt.name, opts, err)
+			}
+			if !reflect.DeepEqual(reflect.ValueOf(gen).Elem().Interface(), reflect.ValueOf(ref).Elem().Convert(reflect.TypeOf(gen).Elem()).Interface()) {
+				t.Fatalf("%s %+v: generated and reflection decoding differ:\nexp: %+v\ngot: %+v", tt.name, opts, ref, gen)
+			}
+		}
+	}
+}
+
+func TestGeneratedCycle(t *testing.T) {
+	n := &Node{Value: 1}
+	n.Next = &Node{Value: 2, Next: n}
+	if _, err := binny.Marshal(n); !errors.Is(err, binny.ErrCycle) {
+		t.Fatalf("expected ErrCycle, got %v", err)
+	}
+}
+
+func TestGeneratedReferences(t *testing.T) {
+	n := &Node{Value: 1}
+	n.Next = &Node{Value: 2, Next: n}
+	var buf bytes.Buffer
+	if err := binny.NewEncoderOptions(&buf, binny.EncoderOptions{References: true}).Encode(n); err != nil {
+		t.Fatal(err)
+	}
+
+	var out Node
+	if err := binny.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Value != 1 || out.Next.Value != 2 || out.Next.Next != &out {
+		t.Fatalf("the cycle wasn't rebuilt: %+v", out)
+	}
+}
+
+func TestGeneratedUnknownFields(t *testing.T) {
+	b, err := binny.Marshal(&extended{ID: 1, Name: "x", Extra: []int{1, 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var e Embedded
+	if err = binny.Unmarshal(b, &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.ID != 1 || e.Name != "x" {
+		t.Fatalf("unexpected value: %+v", e)
+	}
+
+	dec := binny.NewDecoderOptions(bytes.NewReader(b), binny.DecoderOptions{DisallowUnknownFields: true})
+	var ufe *binny.UnknownFieldError
+	if err = dec.Decode(&e); !errors.As(err, &ufe) || ufe.Field != "Extra" || ufe.Type != reflect.TypeOf(e) {
+		t.Fatalf("expected an UnknownFieldError, got %v", err)
+	}
+}
+
+// extended has an extra field compared to Embedded.
+type extended struct {
+	Extra []int
+	ID    int
+	Name  string `binny:"name"`
+}
+
+func encode(t *testing.T, v interface{}, opts binny.EncoderOptions) []byte {
+	var buf bytes.Buffer
+	enc := binny.NewEncoderOptions(&buf, opts)
+	if err := enc.Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func BenchmarkGenerated(b *testing.B) {
+	benchmarkRoundTrip(b, &basic, new(Basic))
+}
+
+func BenchmarkReflection(b *testing.B) {
+	benchmarkRoundTrip(b, (*plainBasic)(&basic), new(plainBasic))
+}
+
+func benchmarkRoundTrip(b *testing.B, v, out interface{}) {
+	var buf bytes.Buffer
+	enc := binny.NewEncoder(&buf)
+	dec := binny.NewDecoder(&buf)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := enc.Encode(v); err != nil {
+			b.Fatal(err)
+		}
+		if err := dec.Decode(out); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
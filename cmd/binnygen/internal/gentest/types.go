@@ -0,0 +1,97 @@
+// Package gentest has the types binnygen's tests generate code for.
+package gentest
+
+//go:generate go run ../.. .
+
+import (
+	"math/big"
+	"time"
+)
+
+type Kind uint8
+
+type Basic struct {
+	Str     string
+	Ignore  string `binny:"-"`
+	I       int
+	I8      int8
+	I16     int16
+	I32     int32
+	I64     int64
+	U       uint
+	U8      uint8
+	U16     uint16
+	U32     uint32
+	U64     uint64
+	Uptr    uintptr
+	F32     float32
+	F64     float64
+	C64     complex64
+	C128    complex128
+	B       bool
+	Bytes   []byte
+	Renamed int `binny:"renamed"`
+	Kind    Kind
+	Dur     time.Duration
+
+	unexported int
+}
+
+type Nested struct {
+	Basic   Basic
+	PBasic  *Basic `binny:"pb"`
+	Basics  []Basic
+	Map     map[string]int
+	Arr     [2]uint16
+	Iface   interface{}
+	Ints    []int
+	Big     *big.Int
+	When    time.Time
+	Empty   Empty
+	Fn      func()
+	Ch      chan int
+	Strings map[int]string
+}
+
+type Embedded struct {
+	ID   int
+	Name string `binny:"name"`
+}
+
+type Inner struct {
+	ID    int // same depth as Embedded.ID, so neither gets used
+	X, Y  float64
+	Inner string
+}
+
+type Promoted struct {
+	Embedded
+	*Inner
+	Name  string // hidden by Embedded's tagged name
+	Other string
+}
+
+type Tagged struct {
+	Omit  int   `binny:"omit,omitempty"`
+	Keep  int   `binny:"keep,keepzero"`
+	Ints  []int `binny:",alwaysemit"`
+	Map   map[string]string
+	Ptr   *Inner
+	Iface interface{}
+	Pt    Embedded `binny:"pt,inline"`
+	Num   int16    `binny:",string"`
+	Float float32  `binny:",string"`
+	Ok    bool     `binny:",string"`
+	Kind  *Kind    `binny:",string"`
+}
+
+type Node struct {
+	Value int
+	Next  *Node
+}
+
+type Empty struct{}
+
+type Unexported struct {
+	a, b int
+}
@@ -0,0 +1,72 @@
+// binnygen generates MarshalBinny and UnmarshalBinny methods for struct types,
+// so they can be encoded and decoded without reflection.
+//
+// The generated methods write exactly what the reflection encoder would write for the same struct,
+// binny tags and embedded fields are handled the same way, so both can be mixed freely.
+//
+// Usage:
+//
+//	//go:generate binnygen -type T,U
+//
+// With no -type flag, methods are generated for every struct type in the package.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	typeNames = flag.String("type", "", "comma-separated list of type names, defaults to all the struct types in the package")
+	output    = flag.String("output", "", "output file name, defaults to <dir>/binny_gen.go")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage of binnygen:\n")
+	fmt.Fprintf(os.Stderr, "\tbinnygen [flags] [directory]\n")
+	fmt.Fprintf(os.Stderr, "Flags:\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("binnygen: ")
+	flag.Usage = usage
+	flag.Parse()
+
+	dir := "."
+	switch flag.NArg() {
+	case 0:
+	case 1:
+		dir = flag.Arg(0)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	outName := *output
+	if outName == "" {
+		outName = filepath.Join(dir, "binny_gen.go")
+	}
+
+	var names []string
+	if *typeNames != "" {
+		names = strings.Split(*typeNames, ",")
+	}
+
+	src, err := generate(dir, filepath.Base(outName), names)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if src, err = format.Source(src); err != nil {
+		log.Fatalf("internal error, invalid generated code: %v", err)
+	}
+	if err = os.WriteFile(outName, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build go1.22
+
+package main
+
+import "go/types"
+
+// unalias returns the type an alias stands for, go/types has only had explicit aliases since Go 1.22.
+func unalias(t types.Type) types.Type { return types.Unalias(t) }
@@ -0,0 +1,8 @@
+//go:build !go1.22
+
+package main
+
+import "go/types"
+
+// unalias is a no-op before Go 1.22, where an alias and the type it stands for are the same types.Type.
+func unalias(t types.Type) types.Type { return t }
@@ -0,0 +1,308 @@
+package binny
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Codec compresses the blocks of a stream, see EncoderOptions.Compression.
+type Codec interface {
+	// Compress appends the compressed src to dst and returns the result.
+	Compress(dst, src []byte) ([]byte, error)
+	// Decompress decompresses src into dst, which has the exact size of the original data,
+	// it must fail if src decompresses to anything else.
+	Decompress(dst, src []byte) error
+}
+
+// CodecFlate is the ID of the built-in Codec that uses compress/flate with the default compression level.
+const CodecFlate uint8 = 1
+
+const (
+	// DefaultBlockSize is the default max size of a block before compression.
+	DefaultBlockSize = 64 << 10
+
+	maxBlockSize = 16 << 20
+)
+
+// ErrNotCompressed gets returned when trying to use blocks on a stream that isn't compressed.
+var ErrNotCompressed = errors.New("stream isn't compressed")
+
+var errBlockTooLong = errors.New("decompressed data is longer than the block")
+
+var codecs = struct {
+	sync.RWMutex
+	m map[uint8]Codec
+}{
+	m: map[uint8]Codec{},
+}
+
+func init() {
+	RegisterCodec(CodecFlate, &flateCodec{})
+}
+
+// RegisterCodec makes a Codec available under the given ID, which gets written in the Header of compressed streams.
+// Both ends of the stream have to register the same codecs, registering ID 0 or the same ID twice panics.
+func RegisterCodec(id uint8, c Codec) {
+	if id == 0 {
+		panic("binny: attempt to register a codec with ID 0")
+	}
+	if c == nil {
+		panic("binny: attempt to register a nil codec")
+	}
+
+	codecs.Lock()
+	defer codecs.Unlock()
+	if _, ok := codecs.m[id]; ok {
+		panic(fmt.Sprintf("binny: registering duplicate codecs for ID %d", id))
+	}
+	codecs.m[id] = c
+}
+
+func registeredCodec(id uint8) (c Codec, ok bool) {
+	codecs.RLock()
+	c, ok = codecs.m[id]
+	codecs.RUnlock()
+	return
+}
+
+// blockWriter splits what it's given into blocks and writes them compressed.
+//
+//	block = [varuint(len(raw))][varuint(len(data))][data]
+//
+// data is stored as is if compressing it doesn't make it smaller, so len(data) == len(raw) means it isn't compressed.
+type blockWriter struct {
+	w    io.Writer
+	id   uint8
+	size int
+	off  int64 // bytes written to w since the last reset
+
+	data, buf []byte
+}
+
+func (bw *blockWriter) reset(w io.Writer) {
+	bw.w, bw.off = w, 0
+}
+
+func (bw *blockWriter) Write(p []byte) (int, error) {
+	codec, ok := registeredCodec(bw.id)
+	if !ok {
+		return 0, fmt.Errorf("unknown codec %d", bw.id)
+	}
+	for n := 0; n < len(p); {
+		raw := p[n:]
+		if len(raw) > bw.size {
+			raw = raw[:bw.size]
+		}
+		data, err := codec.Compress(bw.data[:0], raw)
+		if err != nil {
+			return n, err
+		}
+		bw.data = data
+		if len(data) >= len(raw) {
+			data = raw
+		}
+		bw.buf = binary.AppendUvarint(bw.buf[:0], uint64(len(raw)))
+		bw.buf = binary.AppendUvarint(bw.buf, uint64(len(data)))
+		bw.buf = append(bw.buf, data...)
+		if err = bw.writeRaw(bw.buf); err != nil {
+			return n, err
+		}
+		n += len(raw)
+	}
+	return len(p), nil
+}
+
+// writeRaw writes b without compressing it.
+func (bw *blockWriter) writeRaw(b []byte) error {
+	n, err := bw.w.Write(b)
+	bw.off += int64(n)
+	return err
+}
+
+// blockReader reads the blocks written by a blockWriter and returns their decompressed contents.
+type blockReader struct {
+	r     *bufio.Reader
+	src   io.Reader // what r reads from, see Decoder.SeekBlock
+	codec Codec
+
+	block []byte // the current block
+	pos   int
+	data  []byte
+}
+
+func (br *blockReader) Read(p []byte) (int, error) {
+	for br.pos == len(br.block) {
+		if err := br.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, br.block[br.pos:])
+	br.pos += n
+	return n, nil
+}
+
+func (br *blockReader) next() error {
+	rawLen, err := binary.ReadUvarint(br.r)
+	if err != nil {
+		return err // io.EOF only if the stream ended right between two blocks
+	}
+	n, err := binary.ReadUvarint(br.r)
+	if err != nil {
+		return noEOF(err)
+	}
+	if rawLen > maxBlockSize || n > rawLen {
+		return fmt.Errorf("invalid block (%d bytes, %d compressed)", rawLen, n)
+	}
+	if cap(br.data) < int(n) {
+		br.data = make([]byte, n)
+	}
+	data := br.data[:n]
+	if _, err = io.ReadFull(br.r, data); err != nil {
+		return noEOF(err)
+	}
+	if n == rawLen {
+		// swap the buffers so the next block doesn't overwrite this one
+		br.block, br.data, br.pos = data, br.block, 0
+		return nil
+	}
+	if cap(br.block) < int(rawLen) {
+		br.block = make([]byte, rawLen)
+	}
+	br.block, br.pos = br.block[:rawLen], 0
+	if err = br.codec.Decompress(br.block, data); err != nil {
+		br.block = br.block[:0]
+		return fmt.Errorf("invalid block: %w", err)
+	}
+	return nil
+}
+
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// startBlocks switches the decoder to read the compressed blocks that follow the header.
+func (dec *Decoder) startBlocks(c Codec) {
+	br := &blockReader{codec: c}
+	if dec.r == nil {
+		src := bytes.NewReader(dec.src)
+		src.Seek(int64(dec.pos), io.SeekStart)
+		br.r, br.src = bufio.NewReaderSize(src, dec.opts.BufferSize), src
+		dec.src, dec.pos = nil, 0
+	} else {
+		br.r, br.src = dec.r, dec.in
+	}
+	dec.blocks = br
+	dec.r = bufio.NewReaderSize(br, dec.opts.BufferSize)
+}
+
+// SeekBlock moves the decoder to the compressed block that starts at off, as returned by Encoder.FlushBlock,
+// without reading the blocks before it. The decoder must be reading from a byte slice or an io.Seeker,
+// which off is relative to the start of.
+//
+// Since the blocks before it aren't read, the values after off can't use field names interned
+// or pointers referenced before it, see EncoderOptions.InternFieldNames and EncoderOptions.References.
+func (dec *Decoder) SeekBlock(off int64) error {
+	if err := dec.checkHeader(); err != nil {
+		return err
+	}
+	br := dec.blocks
+	if br == nil {
+		return ErrNotCompressed
+	}
+	s, ok := br.src.(io.Seeker)
+	if !ok {
+		return fmt.Errorf("can't seek in a %T", br.src)
+	}
+	if _, err := s.Seek(off, io.SeekStart); err != nil {
+		return err
+	}
+	br.r.Reset(br.src)
+	br.block, br.pos = br.block[:0], 0
+	dec.r.Reset(br)
+	dec.depth = 0
+	dec.refs, dec.nested = nil, 0
+	dec.open, dec.toks, dec.tokPrefix = dec.open[:0], dec.toks[:0], false
+	return nil
+}
+
+// FlushBlock flushes the buffered data as a compressed block and returns the offset in the output where
+// the next block will start, a Decoder can start reading from there with SeekBlock.
+func (enc *Encoder) FlushBlock() (int64, error) {
+	if !enc.compressed() {
+		return 0, ErrNotCompressed
+	}
+	err := enc.Flush()
+	return enc.blocks.off, err
+}
+
+// compressed reports whether the encoder writes compressed blocks, see EncoderOptions.Compression.
+func (enc *Encoder) compressed() bool {
+	return enc.w != nil && enc.blocks != nil
+}
+
+// output returns what the encoder's buffer should write to.
+func (enc *Encoder) output(w io.Writer) io.Writer {
+	if enc.opts.Compression == 0 {
+		return w
+	}
+	if enc.blocks == nil {
+		enc.blocks = &blockWriter{id: enc.opts.Compression, size: enc.opts.BlockSize}
+	}
+	enc.blocks.reset(w)
+	return enc.blocks
+}
+
+type flateCodec struct {
+	writers, readers sync.Pool
+}
+
+func (fc *flateCodec) Compress(dst, src []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+	w, _ := fc.writers.Get().(*flate.Writer)
+	if w == nil {
+		w, _ = flate.NewWriter(buf, flate.DefaultCompression)
+	} else {
+		w.Reset(buf)
+	}
+	defer fc.writers.Put(w)
+	if _, err := w.Write(src); err != nil {
+		return dst, err
+	}
+	if err := w.Close(); err != nil {
+		return dst, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (fc *flateCodec) Decompress(dst, src []byte) error {
+	br := bytes.NewReader(src)
+	r, _ := fc.readers.Get().(io.ReadCloser)
+	if r == nil {
+		r = flate.NewReader(br)
+	} else if err := r.(flate.Resetter).Reset(br, nil); err != nil {
+		return err
+	}
+	defer fc.readers.Put(r)
+	if _, err := io.ReadFull(r, dst); err != nil {
+		return err
+	}
+	// the data has to end right there, otherwise the length in the block is wrong
+	var b [1]byte
+	switch _, err := io.ReadFull(r, b[:]); err {
+	case io.EOF:
+		return nil
+	case nil:
+		return errBlockTooLong
+	default:
+		return err
+	}
+}
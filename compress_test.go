@@ -0,0 +1,209 @@
+package binny
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// rleCodec writes runs of the same byte as [count][byte].
+type rleCodec struct{}
+
+func (rleCodec) Compress(dst, src []byte) ([]byte, error) {
+	for i := 0; i < len(src); {
+		n := 1
+		for i+n < len(src) && n < 255 && src[i+n] == src[i] {
+			n++
+		}
+		dst = append(dst, byte(n), src[i])
+		i += n
+	}
+	return dst, nil
+}
+
+func (rleCodec) Decompress(dst, src []byte) error {
+	for i := 0; i+1 < len(src); i += 2 {
+		n := int(src[i])
+		if n > len(dst) {
+			return errors.New("run is too long")
+		}
+		for j := 0; j < n; j++ {
+			dst[j] = src[i+1]
+		}
+		dst = dst[n:]
+	}
+	if len(dst) != 0 {
+		return errors.New("short input")
+	}
+	return nil
+}
+
+const codecRLE = 200
+
+func init() {
+	RegisterCodec(codecRLE, rleCodec{})
+}
+
+func TestCompression(t *testing.T) {
+	exp := benchVal
+	exp.Ignore = ""
+	random := make([]byte, 3000)
+	rand.New(rand.NewSource(1)).Read(random)
+
+	for i, opts := range []EncoderOptions{
+		{Compression: CodecFlate},
+		{Compression: CodecFlate, BlockSize: 100},
+		{Compression: CodecFlate, Compact: true, InternFieldNames: true},
+	} {
+		var buf bytes.Buffer
+		enc := NewEncoderOptions(&buf, opts)
+		// without it every value is in its own block, otherwise they can get split between blocks
+		enc.NoAutoFlushOnEncode = i > 0
+		for i := 0; i < 100; i++ {
+			if err := enc.Encode(&benchVal); err != nil {
+				t.Fatal(err)
+			}
+		}
+		enc.Encode(random)
+		if err := enc.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		plain, _ := Marshal(&benchVal)
+		// 100 bytes blocks are too small to compress
+		if size := len(plain)*100 + len(random); i == 0 && buf.Len() >= size || i == 2 && buf.Len() > size/4 {
+			t.Fatalf("%+v: %d bytes isn't compressed enough", opts, buf.Len())
+		}
+
+		for _, dec := range []*Decoder{NewDecoder(bytes.NewReader(buf.Bytes())), NewBytesDecoder(buf.Bytes())} {
+			for j := 0; j < 100; j++ {
+				var s S
+				if err := dec.Decode(&s); err != nil {
+					t.Fatalf("%+v: %d: %v", opts, j, err)
+				}
+				if !reflect.DeepEqual(exp, s) {
+					t.Fatalf("%+v: exp: %+v\ngot: %+v", opts, exp, s)
+				}
+			}
+			var b []byte
+			if err := dec.Decode(&b); err != nil || !bytes.Equal(b, random) {
+				t.Fatalf("%+v: unexpected value: %v", opts, err)
+			}
+			if h := dec.Header(); h == nil || h.Flags&FlagCompressed == 0 || h.Codec != CodecFlate || h.Version != 2 {
+				t.Fatalf("unexpected header: %+v", h)
+			}
+			if _, err := dec.PeekType(); err != io.EOF {
+				t.Fatalf("expected io.EOF, got %v", err)
+			}
+		}
+
+		b := buf.Bytes()
+		for _, i := range []int{len(b) - 1, len(b) / 2, 10} {
+			var v []S
+			if err := Unmarshal(b[:i], &v); err == nil {
+				t.Fatalf("%+v: expected an error decoding %d bytes", opts, i)
+			}
+		}
+	}
+
+	// the data has to decompress to exactly the length of the block
+	fc := &flateCodec{}
+	in := bytes.Repeat([]byte("binny"), 100)
+	z, err := fc.Compress(nil, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{len(in) - 1, len(in), len(in) + 1} {
+		out := make([]byte, n)
+		if err = fc.Decompress(out, z); (err == nil) != (n == len(in)) {
+			t.Fatalf("decompressing %d bytes into %d: %v", len(in), n, err)
+		}
+	}
+	if err = fc.Decompress(make([]byte, len(in)), z); err != nil {
+		t.Fatal(err) // the pooled reader still works
+	}
+
+	enc := NewBytesEncoderOptions(nil, EncoderOptions{Compression: CodecFlate})
+	enc.Encode(1)
+	if !bytes.Equal(enc.Bytes(), []byte{byte(Int8), 1}) {
+		t.Fatalf("a bytes encoder shouldn't compress: %v", enc.Bytes())
+	}
+}
+
+func TestCodecs(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoderOptions(&buf, EncoderOptions{Compression: codecRLE})
+	in := []string{strings.Repeat("a", 100), strings.Repeat("b", 1000)}
+	enc.Encode(in)
+	enc.Flush()
+	var out []string
+	if err := Unmarshal(buf.Bytes(), &out); err != nil || !reflect.DeepEqual(in, out) {
+		t.Fatalf("unexpected value: %v, %v", out, err)
+	}
+
+	b := Header{Version: 2, Flags: FlagCompressed, Codec: 123}.appendTo(nil)
+	var he *HeaderError
+	if err := Unmarshal(b, &out); !errors.As(err, &he) {
+		t.Fatalf("expected a HeaderError, got %v", err)
+	}
+
+	buf.Reset()
+	enc = NewEncoderOptions(&buf, EncoderOptions{Compression: 123})
+	if err := enc.Encode(in); err == nil {
+		t.Fatal("expected an error using an unknown codec")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic registering a codec twice")
+		}
+	}()
+	RegisterCodec(CodecFlate, rleCodec{})
+}
+
+func TestSeekBlock(t *testing.T) {
+	var (
+		buf  bytes.Buffer
+		offs []int64
+	)
+	enc := NewEncoderOptions(&buf, EncoderOptions{Compression: CodecFlate, References: true})
+	enc.NoAutoFlushOnEncode = true
+	for i := 0; i < 10; i++ {
+		off, err := enc.FlushBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		offs = append(offs, off)
+		enc.Encode(&S{Str: "value", I64: int64(i)})
+	}
+	enc.Flush()
+
+	for _, dec := range []*Decoder{NewDecoder(bytes.NewReader(buf.Bytes())), NewBytesDecoder(buf.Bytes())} {
+		for _, i := range []int{7, 2, 9, 0} {
+			if err := dec.SeekBlock(offs[i]); err != nil {
+				t.Fatal(err)
+			}
+			var s S
+			if err := dec.Decode(&s); err != nil {
+				t.Fatal(err)
+			}
+			if s.I64 != int64(i) {
+				t.Fatalf("expected value %d, got %+v", i, s)
+			}
+		}
+	}
+
+	if err := NewDecoder(&buf).SeekBlock(offs[1]); err == nil {
+		t.Fatal("expected an error seeking in a bytes.Buffer")
+	}
+	b, _ := Marshal(1)
+	if err := NewBytesDecoder(b).SeekBlock(0); err != ErrNotCompressed {
+		t.Fatalf("expected ErrNotCompressed, got %v", err)
+	}
+	if _, err := NewEncoder(&buf).FlushBlock(); err != ErrNotCompressed {
+		t.Fatalf("expected ErrNotCompressed, got %v", err)
+	}
+}
@@ -2,13 +2,14 @@ package binny
 
 import (
 	"bufio"
-	"bytes"
 	"encoding"
 	"encoding/binary"
 	"encoding/gob"
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"math/bits"
 	"reflect"
 	"unsafe"
 )
@@ -20,15 +21,85 @@ var (
 
 const DefaultDecoderBufferSize = 4096
 
+// The limits used when the matching DecoderOptions field is 0.
+const (
+	DefaultMaxBytesLen = 1 << 30
+	DefaultMaxSliceLen = 1 << 30
+	DefaultMaxMapLen   = 1 << 30
+	DefaultMaxDepth    = 10000
+)
+
 // Unmarshaler is the interface implemented by objects that can unmarshal a binary representation of themselves.
 // Implementing this bypasses reflection and is generally faster.
 type Unmarshaler interface {
 	UnmarshalBinny(dec *Decoder) error
 }
 
+// DecoderOptions are the options for NewDecoderOptions.
+type DecoderOptions struct {
+	// BufferSize is the size of the internal buffer, defaults to DefaultDecoderBufferSize, minimum is 16 bytes.
+	BufferSize int
+
+	// ByteOrder is used to read fixed-width numbers, defaults to binary.LittleEndian.
+	// Use NativeEndian to read data written by older versions of binny.
+	// It's ignored if the stream starts with a Header.
+	ByteOrder binary.ByteOrder
+
+	// RequireHeader makes the decoder fail with ErrNoHeader if the stream doesn't start with a Header,
+	// otherwise the header is optional and gets detected automatically.
+	RequireHeader bool
+
+	// Compact must be set to read a stream written with EncoderOptions.Compact that doesn't start with a Header.
+	Compact bool
+
+	// DisallowUnknownFields makes the decoder return an *UnknownFieldError when the input has a field
+	// that doesn't exist in the destination struct, by default unknown fields are skipped.
+	DisallowUnknownFields bool
+
+	// Limits for decoding untrusted input, exceeding any of them returns a *LimitError.
+	// 0 means the matching DefaultMax* limit and a negative value means no limit, except for MaxTotalBytes
+	// which has no default because a stream can go on forever.
+	MaxBytesLen   int   // max length of a string, []byte, Binary or Gob value
+	MaxSliceLen   int   // max number of elements in a slice or fields in a packed struct
+	MaxMapLen     int   // max number of entries in a map
+	MaxDepth      int   // max nesting of structs, maps, slices and interfaces
+	MaxTotalBytes int64 // max number of bytes read from the stream since the last Reset
+
+	// NoCopy makes a decoder created with NewBytesDecoderOptions return strings and byte slices
+	// that point into its input instead of copying them, so the input must not be modified while they're in use.
+	// It's ignored when reading from an io.Reader.
+	NoCopy bool
+}
+
 // A Decoder reads binary data from an input stream, it also does a little bit of buffering.
 type Decoder struct {
-	r *bufio.Reader
+	r       *bufio.Reader
+	src     []byte // the input when reading directly from a byte slice, r is nil then
+	pos     int
+	in      io.Reader    // what r was reset with
+	blocks  *blockReader // what r reads from when the stream is compressed, see EncoderOptions.Compression
+	order   binary.ByteOrder
+	compact bool // lengths are bare varints, see EncoderOptions.Compact
+	opts    DecoderOptions
+
+	hdr     *Header
+	hdrDone bool
+	hdrErr  error
+
+	off   int64 // number of bytes read since the last Reset
+	depth int
+
+	raw       []byte // bytes read while capturing, see ReadRaw
+	capturing bool
+
+	syms []string // the stream's symbol table, see EncoderOptions.InternFieldNames
+
+	refs   []reflect.Value // pointers that can be referenced, see EncoderOptions.References
+	nested int             // Decode calls in progress, references are scoped to the outermost one
+
+	open      []Type       // slices and maps started with BeginSlice or BeginMap, Nil for a Nil entry
+	toks      []tokenFrame // structs, maps and slices being read by Token
+	tokPrefix bool         // Token returned an InterfaceName or RefID, the value it applies to is next
 
 	buf [16]byte
 }
@@ -43,30 +114,277 @@ func NewDecoder(r io.Reader) *Decoder {
 // The decoder introduces its own buffering and may
 // read data from r beyond the requested values.
 func NewDecoderSize(r io.Reader, sz int) *Decoder {
-	if sz < 16 {
-		sz = 16
-	}
+	return NewDecoderOptions(r, DecoderOptions{BufferSize: sz})
+}
 
+// NewDecoderOptions returns a new decoder that reads from r with the specified options.
+func NewDecoderOptions(r io.Reader, opts DecoderOptions) *Decoder {
+	dec := newDecoder(opts)
+	dec.r, dec.in = bufio.NewReaderSize(r, dec.opts.BufferSize), r
+	return dec
+}
+
+// NewBytesDecoder is an alias for NewBytesDecoderOptions(b, DecoderOptions{})
+func NewBytesDecoder(b []byte) *Decoder {
+	return NewBytesDecoderOptions(b, DecoderOptions{})
+}
+
+// NewBytesDecoderOptions returns a new decoder that reads directly from b, without any buffering or copying,
+// see DecoderOptions.NoCopy to avoid copying strings and byte slices as well.
+func NewBytesDecoderOptions(b []byte, opts DecoderOptions) *Decoder {
+	dec := newDecoder(opts)
+	dec.src = b
+	return dec
+}
+
+func newDecoder(opts DecoderOptions) *Decoder {
+	if opts.BufferSize == 0 {
+		opts.BufferSize = DefaultDecoderBufferSize
+	}
+	if opts.BufferSize < 16 {
+		opts.BufferSize = 16
+	}
+	if opts.ByteOrder == nil {
+		opts.ByteOrder = binary.LittleEndian
+	}
+	if opts.MaxBytesLen == 0 {
+		opts.MaxBytesLen = DefaultMaxBytesLen
+	}
+	if opts.MaxSliceLen == 0 {
+		opts.MaxSliceLen = DefaultMaxSliceLen
+	}
+	if opts.MaxMapLen == 0 {
+		opts.MaxMapLen = DefaultMaxMapLen
+	}
+	if opts.MaxDepth == 0 {
+		opts.MaxDepth = DefaultMaxDepth
+	}
 	return &Decoder{
-		r: bufio.NewReaderSize(r, sz),
+		order:   opts.ByteOrder,
+		compact: opts.Compact,
+		opts:    opts,
 	}
 }
 
+// Options returns the options the decoder was created with.
+func (dec *Decoder) Options() DecoderOptions {
+	return dec.opts
+}
+
 // Reset discards any buffered data, resets all state, and switches
 // the buffered reader to read from r.
 func (dec *Decoder) Reset(r io.Reader) {
-	dec.r.Reset(r)
+	if dec.r == nil {
+		dec.r = bufio.NewReaderSize(r, dec.opts.BufferSize)
+	} else {
+		dec.r.Reset(r)
+	}
+	dec.in, dec.src, dec.pos = r, nil, 0
+	dec.reset()
+}
+
+// ResetBytes resets all state like Reset, and switches the decoder to read directly from b.
+func (dec *Decoder) ResetBytes(b []byte) {
+	dec.r, dec.in = nil, nil
+	dec.src, dec.pos = b, 0
+	dec.reset()
+}
+
+func (dec *Decoder) reset() {
+	dec.order, dec.compact = dec.opts.ByteOrder, dec.opts.Compact
+	dec.hdr, dec.hdrDone, dec.hdrErr = nil, false, nil
+	dec.blocks = nil
+	dec.off, dec.depth = 0, 0
+	dec.raw, dec.capturing = nil, false
+	dec.syms = nil
+	dec.refs, dec.nested = nil, 0
+	dec.open, dec.toks, dec.tokPrefix = dec.open[:0], dec.toks[:0], false
+}
+
+// readByte, readFull, next, discard and read[U]varint are the only functions that should consume the input.
+func (dec *Decoder) readByte() (b byte, err error) {
+	if err = dec.checkTotal(1); err != nil {
+		return
+	}
+	if dec.r != nil {
+		b, err = dec.r.ReadByte()
+	} else if dec.pos < len(dec.src) {
+		b = dec.src[dec.pos]
+		dec.pos++
+	} else {
+		err = io.EOF
+	}
+	if err == nil {
+		dec.off++
+		if dec.capturing {
+			dec.raw = append(dec.raw, b)
+		}
+	}
+	return b, err
+}
+
+func (dec *Decoder) readFull(p []byte) (int, error) {
+	if err := dec.checkTotal(uint64(len(p))); err != nil {
+		return 0, err
+	}
+	var (
+		n   int
+		err error
+	)
+	if dec.r != nil {
+		n, err = io.ReadFull(dec.r, p)
+	} else {
+		n = copy(p, dec.src[dec.pos:])
+		dec.pos += n
+		if n == 0 && len(p) > 0 {
+			err = io.EOF
+		} else if n < len(p) {
+			err = io.ErrUnexpectedEOF
+		}
+	}
+	dec.off += int64(n)
+	if dec.capturing {
+		dec.raw = append(dec.raw, p[:n]...)
+	}
+	return n, err
+}
+
+var errOverflow = errors.New("varint overflows a 64-bit integer")
+
+func (dec *Decoder) readUvarint() (uint64, error) {
+	var x uint64
+	var s uint
+	for i := 0; i < binary.MaxVarintLen64; i++ {
+		b, err := dec.readByte()
+		if err != nil {
+			if i > 0 && err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return x, err
+		}
+		if b < 0x80 {
+			if i == binary.MaxVarintLen64-1 && b > 1 {
+				return x, errOverflow
+			}
+			return x | uint64(b)<<s, nil
+		}
+		x |= uint64(b&0x7f) << s
+		s += 7
+	}
+	return x, errOverflow
+}
+
+// next returns the next n bytes of a decoder reading from a byte slice, without copying them.
+func (dec *Decoder) next(n uint64) ([]byte, error) {
+	if n > uint64(len(dec.src)-dec.pos) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if err := dec.checkTotal(n); err != nil {
+		return nil, err
+	}
+	end := dec.pos + int(n)
+	b := dec.src[dec.pos:end:end] // so appending to it can't overwrite the input
+	dec.pos = end
+	dec.off += int64(n)
+	if dec.capturing {
+		dec.raw = append(dec.raw, b...)
+	}
+	return b, nil
+}
+
+// peekByte returns the next byte without consuming it.
+func (dec *Decoder) peekByte() (byte, error) {
+	if dec.r != nil {
+		b, err := dec.r.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		return b[0], nil
+	}
+	if dec.pos < len(dec.src) {
+		return dec.src[dec.pos], nil
+	}
+	return 0, io.EOF
+}
+
+func (dec *Decoder) discard(n uint64) error {
+	if dec.r == nil {
+		_, err := dec.next(n)
+		return err
+	}
+	for n > 0 {
+		chunk := n
+		if chunk > math.MaxInt32 {
+			chunk = math.MaxInt32
+		}
+		if err := dec.checkTotal(chunk); err != nil {
+			return err
+		}
+		var (
+			d   int
+			err error
+		)
+		if dec.capturing {
+			if chunk > uint64(dec.r.Size()) {
+				chunk = uint64(dec.r.Size())
+			}
+			var p []byte
+			p, err = dec.r.Peek(int(chunk))
+			dec.raw = append(dec.raw, p...)
+			d, _ = dec.r.Discard(len(p))
+		} else {
+			d, err = dec.r.Discard(int(chunk))
+		}
+		dec.off += int64(d)
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		n -= chunk
+	}
+	return nil
+}
+
+func (dec *Decoder) readVarint() (int64, error) {
+	ux, err := dec.readUvarint()
+	x := int64(ux >> 1)
+	if ux&1 != 0 {
+		x = ^x
+	}
+	return x, err
 }
 
 func (dec *Decoder) readType() (Type, error) {
-	b, err := dec.r.ReadByte()
+	if err := dec.checkHeader(); err != nil {
+		return Nil, err
+	}
+	b, err := dec.readByte()
 	return Type(b), err
 }
 
 func (dec *Decoder) peekType() Type {
-	b, _ := dec.r.ReadByte()
-	dec.r.UnreadByte()
-	return Type(b)
+	t, _ := dec.PeekType()
+	return t
+}
+
+// PeekType returns the type of the next entry without consuming it.
+func (dec *Decoder) PeekType() (Type, error) {
+	if err := dec.checkHeader(); err != nil {
+		return Nil, err
+	}
+	b, err := dec.peekByte()
+	return Type(b), err
+}
+
+// ReadNil reads the next entry if it's Nil and reports whether it did.
+func (dec *Decoder) ReadNil() (bool, error) {
+	if ft, err := dec.PeekType(); err != nil || ft != Nil {
+		return false, err
+	}
+	_, err := dec.readType()
+	return true, err
 }
 
 func (dec *Decoder) expectType(et Type) error {
@@ -95,7 +413,7 @@ func (dec *Decoder) ReadInt8() (int8, error) {
 	if err := dec.expectType(Int8); err != nil {
 		return 0, err
 	}
-	b, err := dec.r.ReadByte()
+	b, err := dec.readByte()
 	return int8(b), err
 }
 
@@ -105,7 +423,7 @@ func (dec *Decoder) ReadInt16() (int16, error) {
 	}
 	buf := dec.buf[:2]
 	_, err := dec.Read(buf)
-	return *(*int16)(unsafe.Pointer(&buf[0])), err
+	return int16(dec.order.Uint16(buf)), err
 }
 
 func (dec *Decoder) ReadInt32() (int32, error) {
@@ -114,7 +432,7 @@ func (dec *Decoder) ReadInt32() (int32, error) {
 	}
 	buf := dec.buf[:4]
 	_, err := dec.Read(buf)
-	return *(*int32)(unsafe.Pointer(&buf[0])), err
+	return int32(dec.order.Uint32(buf)), err
 }
 
 func (dec *Decoder) ReadInt64() (int64, error) {
@@ -123,19 +441,22 @@ func (dec *Decoder) ReadInt64() (int64, error) {
 	}
 	buf := dec.buf[:8]
 	_, err := dec.Read(buf)
-	return *(*int64)(unsafe.Pointer(&buf[0])), err
+	return int64(dec.order.Uint64(buf)), err
 }
 
 func (dec *Decoder) ReadVarInt() (int64, error) {
 	if err := dec.expectType(VarInt); err != nil {
 		return 0, err
 	}
-	return binary.ReadVarint(dec.r)
+	return dec.readVarint()
 }
 
 // ReadInt retruns an int/varint value and the size of it (8, 16, 32, 64) or an error.
 func (dec *Decoder) ReadInt() (int64, uint8, error) {
-	ft := dec.peekType()
+	ft, err := dec.PeekType()
+	if err != nil {
+		return 0, 0, err
+	}
 	switch ft {
 	case Int8:
 		v, err := dec.ReadInt8()
@@ -160,7 +481,7 @@ func (dec *Decoder) ReadUint8() (uint8, error) {
 	if err := dec.expectType(Uint8); err != nil {
 		return 0, err
 	}
-	return dec.r.ReadByte()
+	return dec.readByte()
 }
 
 func (dec *Decoder) ReadUint16() (uint16, error) {
@@ -169,7 +490,7 @@ func (dec *Decoder) ReadUint16() (uint16, error) {
 	}
 	buf := dec.buf[:2]
 	_, err := dec.Read(buf)
-	return *(*uint16)(unsafe.Pointer(&buf[0])), err
+	return dec.order.Uint16(buf), err
 }
 
 func (dec *Decoder) ReadUint32() (uint32, error) {
@@ -178,7 +499,7 @@ func (dec *Decoder) ReadUint32() (uint32, error) {
 	}
 	buf := dec.buf[:4]
 	_, err := dec.Read(buf)
-	return *(*uint32)(unsafe.Pointer(&buf[0])), err
+	return dec.order.Uint32(buf), err
 }
 
 func (dec *Decoder) ReadUint64() (uint64, error) {
@@ -187,19 +508,22 @@ func (dec *Decoder) ReadUint64() (uint64, error) {
 	}
 	buf := dec.buf[:8]
 	_, err := dec.Read(buf)
-	return *(*uint64)(unsafe.Pointer(&buf[0])), err
+	return dec.order.Uint64(buf), err
 }
 
 func (dec *Decoder) ReadVarUint() (uint64, error) {
 	if err := dec.expectType(VarUint); err != nil {
 		return 0, err
 	}
-	return binary.ReadUvarint(dec.r)
+	return dec.readUvarint()
 }
 
 // ReadUint retruns an uint/varuint value and the size of it (8, 16, 32, 64) or an error.
 func (dec *Decoder) ReadUint() (v uint64, sz uint8, err error) {
-	ft := dec.peekType()
+	ft, err := dec.PeekType()
+	if err != nil {
+		return 0, 0, err
+	}
 	switch ft {
 	case Uint8:
 		v, err := dec.ReadUint8()
@@ -227,7 +551,7 @@ func (dec *Decoder) ReadFloat32() (float32, error) {
 	}
 	buf := dec.buf[:4]
 	_, err := dec.Read(buf)
-	return *(*float32)(unsafe.Pointer(&buf[0])), err
+	return math.Float32frombits(dec.order.Uint32(buf)), err
 }
 
 // ReadFloat64 returns a float64 or an error.
@@ -237,7 +561,7 @@ func (dec *Decoder) ReadFloat64() (float64, error) {
 	}
 	buf := dec.buf[:8]
 	_, err := dec.Read(buf)
-	return *(*float64)(unsafe.Pointer(&buf[0])), err
+	return math.Float64frombits(dec.order.Uint64(buf)), err
 }
 
 // ReadComplex64 returns a complex64 or an error.
@@ -248,7 +572,7 @@ func (dec *Decoder) ReadComplex64() (complex64, error) {
 
 	buf := dec.buf[:8]
 	_, err := dec.Read(buf)
-	return *(*complex64)(unsafe.Pointer(&buf[0])), err
+	return complex(math.Float32frombits(dec.order.Uint32(buf)), math.Float32frombits(dec.order.Uint32(buf[4:]))), err
 }
 
 // ReadComplex128 returns a complex128 or an error.
@@ -258,22 +582,54 @@ func (dec *Decoder) ReadComplex128() (complex128, error) {
 	}
 	buf := dec.buf[:16]
 	_, err := dec.Read(buf)
-	return *(*complex128)(unsafe.Pointer(&buf[0])), err
+	return complex(math.Float64frombits(dec.order.Uint64(buf)), math.Float64frombits(dec.order.Uint64(buf[8:]))), err
 }
 
 func (dec *Decoder) readBytes(exp Type) ([]byte, error) {
 	if err := dec.expectType(exp); err != nil {
 		return nil, err
 	}
+	return dec.readBytesValue()
+}
 
-	sz, _, err := dec.ReadUint()
+// readBytesValue reads the [len][bytes] part of a String, ByteSlice, Binary, Gob or Symbol entry.
+func (dec *Decoder) readBytesValue() ([]byte, error) {
+	sz, err := dec.readSize()
 	if err != nil || sz == 0 {
 		return nil, err
 	}
+	if err = dec.checkLen("bytes length", dec.opts.MaxBytesLen, sz); err != nil {
+		return nil, err
+	}
+	if err = dec.checkTotal(sz); err != nil {
+		return nil, err
+	}
+	if dec.r == nil {
+		// the whole input is there, so a bogus length fails before allocating anything
+		b, err := dec.next(sz)
+		if err != nil || dec.opts.NoCopy {
+			return b, err
+		}
+		return append(make([]byte, 0, len(b)), b...), nil
+	}
 
-	buf := make([]byte, sz)
-	_, err = io.ReadFull(dec.r, buf)
-	return buf, err
+	return dec.readAlloc(sz)
+}
+
+// readAlloc reads sz bytes into a new slice, which grows as the data comes in rather than trusting sz.
+func (dec *Decoder) readAlloc(sz uint64) (buf []byte, err error) {
+	for left := sz; left > 0; {
+		n := left
+		if n > maxPrealloc {
+			n = maxPrealloc
+		}
+		buf = append(buf, make([]byte, n)...)
+		if _, err = dec.readFull(buf[uint64(len(buf))-n:]); err != nil {
+			return nil, err
+		}
+		left -= n
+	}
+	return buf, nil
 }
 
 // ReadBytes returns a byte slice.
@@ -288,6 +644,42 @@ func (dec *Decoder) ReadString() (string, error) {
 	return *(*string)(unsafe.Pointer(&b)), err
 }
 
+// readName reads a struct field or interface name, which is either a String or a symbol.
+func (dec *Decoder) readName() (string, error) {
+	ft, err := dec.readType()
+	if err != nil {
+		return "", err
+	}
+	switch ft {
+	case String:
+		b, err := dec.readBytesValue()
+		return *(*string)(unsafe.Pointer(&b)), err
+	case Symbol:
+		return dec.readSymbol()
+	case SymbolRef:
+		id, err := dec.readUvarint()
+		if err != nil {
+			return "", err
+		}
+		if id >= uint64(len(dec.syms)) {
+			return "", fmt.Errorf("invalid symbol reference: %d", id)
+		}
+		return dec.syms[id], nil
+	}
+	return "", DecoderTypeError{"name", ft}
+}
+
+// readSymbol reads the value of a Symbol entry and adds it to the symbol table.
+func (dec *Decoder) readSymbol() (string, error) {
+	b, err := dec.readBytesValue()
+	if err != nil {
+		return "", err
+	}
+	name := string(b)
+	dec.syms = append(dec.syms, name)
+	return name, nil
+}
+
 // ReadBinary decodes and reads an object that implements the `encoding.BinaryUnmarshaler` interface.
 func (dec *Decoder) ReadBinary(v encoding.BinaryUnmarshaler) error {
 	b, err := dec.readBytes(Binary)
@@ -306,9 +698,396 @@ func (dec *Decoder) ReadGob(v gob.GobDecoder) error {
 	return v.GobDecode(b)
 }
 
+// readValue reads the next value and returns it as a generic Go value:
+//
+//	Nil: nil
+//	BoolTrue, BoolFalse: bool
+//	EmptyStruct: struct{}
+//	VarInt, Int8-Int64: int64
+//	VarUint, Uint8-Uint64: uint64
+//	Float32, Float64, Complex64, Complex128: float32, float64, complex64, complex128
+//	String: string
+//	ByteSlice, Binary, Gob: []byte
+//	Struct: map[string]interface{}
+//	Map: map[string]interface{} if all the keys are strings, otherwise map[interface{}]interface{}
+//	Slice: []interface{}
+//	PackedStruct: []interface{} with an element for every field, nil if the field isn't present
+//	Interface: a value of the registered type (see Register)
+//	Ref, BackRef: the referenced value, a BackRef to a value that's still being decoded is an error
+func (dec *Decoder) readValue() (interface{}, error) {
+	ft, err := dec.PeekType()
+	if err != nil {
+		return nil, err
+	}
+	switch ft {
+	case Nil:
+		_, err = dec.readType()
+		return nil, err
+	case BoolTrue, BoolFalse:
+		return dec.ReadBool()
+	case EmptyStruct:
+		_, err = dec.readType()
+		return struct{}{}, err
+	case VarInt, Int8, Int16, Int32, Int64:
+		v, _, err := dec.ReadInt()
+		return v, err
+	case VarUint, Uint8, Uint16, Uint32, Uint64:
+		v, _, err := dec.ReadUint()
+		return v, err
+	case Float32:
+		return dec.ReadFloat32()
+	case Float64:
+		return dec.ReadFloat64()
+	case Complex64:
+		return dec.ReadComplex64()
+	case Complex128:
+		return dec.ReadComplex128()
+	case String:
+		return dec.ReadString()
+	case ByteSlice, Binary, Gob:
+		return dec.readBytes(ft)
+	case Struct:
+		return dec.readStructValue()
+	case Map:
+		return dec.readMapValue()
+	case Slice:
+		return dec.readSliceValue()
+	case PackedStruct:
+		return dec.readPackedValue()
+	case Interface:
+		v, err := dec.readIface()
+		if err != nil {
+			return nil, err
+		}
+		return v.Interface(), nil
+	case Ref, BackRef:
+		return dec.readRefValue()
+	}
+	return nil, DecoderTypeError{"value", ft}
+}
+
+func (dec *Decoder) readStructValue() (interface{}, error) {
+	if err := dec.expectType(Struct); err != nil {
+		return nil, err
+	}
+	if err := dec.enter(); err != nil {
+		return nil, err
+	}
+	defer dec.leave()
+	m := map[string]interface{}{}
+	for {
+		if dec.peekType() == EOV {
+			_, err := dec.readType()
+			return m, err
+		}
+		n, err := dec.readName()
+		if err != nil {
+			return nil, err
+		}
+		if m[n], err = dec.readValue(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (dec *Decoder) readMapValue() (interface{}, error) {
+	if err := dec.expectType(Map); err != nil {
+		return nil, err
+	}
+	ln, err := dec.readLen("map length", dec.opts.MaxMapLen)
+	if err != nil {
+		return nil, err
+	}
+	if err = dec.enter(); err != nil {
+		return nil, err
+	}
+	defer dec.leave()
+	var keys, vals []interface{}
+	if ln > 0 {
+		if err = dec.checkTotal(uint64(ln)); err != nil {
+			return nil, err
+		}
+		n := dec.prealloc(ln, 2*ifaceSize)
+		keys, vals = make([]interface{}, 0, n), make([]interface{}, 0, n)
+	}
+	strKeys := true
+	for i := 0; ; i++ {
+		if ok, err := dec.hasEntry(i, ln, "map length", dec.opts.MaxMapLen); err != nil {
+			return nil, err
+		} else if !ok {
+			break
+		}
+		k, err := dec.readValue()
+		if err != nil {
+			return nil, err
+		}
+		v, err := dec.readValue()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := k.(string); !ok {
+			strKeys = false
+		}
+		keys, vals = append(keys, k), append(vals, v)
+	}
+	if err = dec.expectType(EOV); err != nil {
+		return nil, err
+	}
+	if strKeys {
+		m := make(map[string]interface{}, len(keys))
+		for i, k := range keys {
+			m[k.(string)] = vals[i]
+		}
+		return m, nil
+	}
+	m := make(map[interface{}]interface{}, len(keys))
+	for i, k := range keys {
+		if k != nil && !reflect.TypeOf(k).Comparable() {
+			return nil, fmt.Errorf("can't use %T as a map key", k)
+		}
+		m[k] = vals[i]
+	}
+	return m, nil
+}
+
+const ifaceSize = unsafe.Sizeof(interface{}(nil))
+
+func (dec *Decoder) readSliceValue() (interface{}, error) {
+	if err := dec.expectType(Slice); err != nil {
+		return nil, err
+	}
+	ln, err := dec.readLen("slice length", dec.opts.MaxSliceLen)
+	if err != nil {
+		return nil, err
+	}
+	if err = dec.enter(); err != nil {
+		return nil, err
+	}
+	defer dec.leave()
+	n := 0
+	if ln > 0 {
+		if err = dec.checkTotal(uint64(ln)); err != nil {
+			return nil, err
+		}
+		n = dec.prealloc(ln, ifaceSize)
+	}
+	s := make([]interface{}, 0, n)
+	for i := 0; ; i++ {
+		if ok, err := dec.hasEntry(i, ln, "slice length", dec.opts.MaxSliceLen); err != nil {
+			return nil, err
+		} else if !ok {
+			break
+		}
+		v, err := dec.readValue()
+		if err != nil {
+			return nil, err
+		}
+		s = append(s, v)
+	}
+	return s, dec.expectType(EOV)
+}
+
+func (dec *Decoder) readPackedValue() (interface{}, error) {
+	if err := dec.expectType(PackedStruct); err != nil {
+		return nil, err
+	}
+	pb, err := dec.readPackedBitmap()
+	if err != nil {
+		return nil, err
+	}
+	if err = dec.enter(); err != nil {
+		return nil, err
+	}
+	defer dec.leave()
+	// every field that's present takes at least a byte, the absent ones only a bit of the bitmap
+	count := pb.count()
+	if err = dec.checkTotal(uint64(count)); err != nil {
+		return nil, err
+	}
+	s := make([]interface{}, 0, dec.prealloc(pb.n, ifaceSize))
+	for i := 0; i < pb.n; i++ {
+		var v interface{}
+		if pb.has(i) {
+			if v, err = dec.readValue(); err != nil {
+				return nil, err
+			}
+		}
+		s = append(s, v)
+	}
+	return s, nil
+}
+
+// Skip consumes exactly one complete entry of any type, including everything nested inside
+// a Struct, Map, Slice or Interface, without decoding it.
+func (dec *Decoder) Skip() error {
+	ft, err := dec.readType()
+	if err != nil {
+		return err
+	}
+	switch ft {
+	case Nil, BoolTrue, BoolFalse, EmptyStruct:
+		return nil
+	case Int8, Uint8:
+		return dec.discard(1)
+	case Int16, Uint16:
+		return dec.discard(2)
+	case Int32, Uint32, Float32:
+		return dec.discard(4)
+	case Int64, Uint64, Float64, Complex64:
+		return dec.discard(8)
+	case Complex128:
+		return dec.discard(16)
+	case VarInt, VarUint, SymbolRef, BackRef:
+		_, err = dec.readUvarint()
+		return err
+	case Symbol:
+		_, err = dec.readSymbol() // it still has to be added to the symbol table
+		return err
+	case String, ByteSlice, Binary, Gob:
+		ln, err := dec.readSize()
+		if err != nil {
+			return err
+		}
+		return dec.discard(ln)
+	case Struct:
+		return dec.skipStruct()
+	case PackedStruct:
+		pb, err := dec.readPackedBitmap()
+		if err != nil {
+			return err
+		}
+		return dec.skipN(uint64(pb.count()), false)
+	case Map, Slice:
+		limit, max := "slice length", dec.opts.MaxSliceLen
+		if ft == Map {
+			limit, max = "map length", dec.opts.MaxMapLen
+		}
+		ln, err := dec.readLen(limit, max)
+		if err != nil {
+			return err
+		}
+		if ln < 0 {
+			return dec.skipUntilEOV()
+		}
+		n := uint64(ln)
+		if ft == Map {
+			n *= 2
+		}
+		return dec.skipN(n, true)
+	case Interface:
+		return dec.skipN(2, false) // name and value
+	case Ref:
+		if err = dec.enter(); err != nil {
+			return err
+		}
+		defer dec.leave()
+		dec.refs = append(dec.refs, reflect.Value{}) // keep the indices of the following refs right
+		return dec.Skip()
+	}
+	return DecoderTypeError{"value", ft}
+}
+
+func (dec *Decoder) skipStruct() error {
+	if err := dec.enter(); err != nil {
+		return err
+	}
+	defer dec.leave()
+	for {
+		ft, err := dec.PeekType()
+		if err != nil {
+			return err
+		}
+		if ft == EOV {
+			_, err = dec.readType()
+			return err
+		}
+		// field name, then its value
+		if err = dec.Skip(); err != nil {
+			return err
+		}
+		if err = dec.Skip(); err != nil {
+			return err
+		}
+	}
+}
+
+// skipUntilEOV skips the entries of a Map or Slice written with an unknown length, and the EOV that ends them.
+func (dec *Decoder) skipUntilEOV() error {
+	if err := dec.enter(); err != nil {
+		return err
+	}
+	defer dec.leave()
+	for {
+		ft, err := dec.PeekType()
+		if err != nil {
+			return err
+		}
+		if ft == EOV {
+			_, err = dec.readType()
+			return err
+		}
+		if err = dec.Skip(); err != nil {
+			return err
+		}
+	}
+}
+
+// skipN skips the n entries of a Map, Slice or Interface and the EOV that follows them if eov is set.
+func (dec *Decoder) skipN(n uint64, eov bool) error {
+	if err := dec.enter(); err != nil {
+		return err
+	}
+	defer dec.leave()
+	for i := uint64(0); i < n; i++ {
+		if err := dec.Skip(); err != nil {
+			return err
+		}
+	}
+	if !eov {
+		return nil
+	}
+	return dec.expectType(EOV)
+}
+
 // Decode reads the next binny-encoded value from its
 // input and stores it in the value pointed to by v.
+// It returns io.EOF at the end of the input and io.ErrUnexpectedEOF if the value was cut short.
 func (dec *Decoder) Decode(v interface{}) (err error) {
+	_ = dec.checkHeader() // so the header isn't counted as part of the value, decode returns any error it has
+	start := dec.off
+	dec.nested++
+	err = dec.decode(v)
+	if dec.nested--; dec.nested == 0 {
+		if len(dec.refs) > 0 && len(dec.toks) == 0 {
+			dec.refs = nil
+		}
+		dec.tokenValueRead()
+	}
+	if dec.off > start {
+		err = unexpectedEOF(err)
+	}
+	return err
+}
+
+// unexpectedEOF turns an io.EOF in err into io.ErrUnexpectedEOF, for a value that was cut short,
+// so io.EOF only ever means the stream ended cleanly between two values.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	if de, ok := err.(*DecodeError); ok && de.Err == io.EOF {
+		de.Err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (dec *Decoder) decode(v interface{}) (err error) {
+	if _, ok := v.(*interface{}); !ok {
+		// pointers written with EncoderOptions.References, they can't take the fast paths below
+		if ft := dec.peekType(); ft == Ref || ft == BackRef {
+			return dec.decodeValue(reflect.ValueOf(v))
+		}
+	}
 	switch v := v.(type) {
 	case Unmarshaler:
 		return v.UnmarshalBinny(dec)
@@ -388,6 +1167,8 @@ func (dec *Decoder) Decode(v interface{}) (err error) {
 	case *bool:
 		*v, err = dec.ReadBool()
 		return
+	case *interface{}:
+		return ifaceDecoder(dec, reflect.ValueOf(v).Elem())
 	case nil:
 		return fmt.Errorf("can't decode a nil value")
 	}
@@ -407,13 +1188,60 @@ func (dec *Decoder) decodeValue(v reflect.Value) error {
 
 // Read allows the Decoder to be used as an io.Reader, note that internally this calls io.ReadFull().
 func (dec *Decoder) Read(p []byte) (int, error) {
-	return io.ReadFull(dec.r, p)
+	if err := dec.checkHeader(); err != nil {
+		return 0, err
+	}
+	return dec.readFull(p)
 }
 
-// Unmarshal is an alias for (sync.Pool'ed) NewDecoder(bytes.NewReader(b)).Decode(v)
+// Unmarshal is an alias for (sync.Pool'ed) NewBytesDecoder(b).Decode(v)
 func Unmarshal(b []byte, v interface{}) error {
-	dec := getDec(bytes.NewReader(b))
+	dec := getDec(b, false)
+	err := dec.Decode(v)
+	putDec(dec)
+	return err
+}
+
+// UnmarshalNoCopy is like Unmarshal, but the strings and byte slices in v point into b instead of being copied,
+// see DecoderOptions.NoCopy.
+func UnmarshalNoCopy(b []byte, v interface{}) error {
+	dec := getDec(b, true)
 	err := dec.Decode(v)
 	putDec(dec)
 	return err
 }
+
+// packedBitmap marks which of the n fields of a PackedStruct are present.
+type packedBitmap struct {
+	n int
+	b []byte
+}
+
+func (pb packedBitmap) has(i int) bool { return pb.b[i/8]&(1<<(i%8)) != 0 }
+
+func (pb packedBitmap) count() (n int) {
+	for _, b := range pb.b {
+		n += bits.OnesCount8(b)
+	}
+	return
+}
+
+// readPackedBitmap reads the [varuint(numFields)][bitmap] part of a PackedStruct.
+func (dec *Decoder) readPackedBitmap() (pb packedBitmap, err error) {
+	n, err := dec.readUvarint()
+	if err != nil {
+		return
+	}
+	if err = dec.checkLen("packed struct fields", dec.opts.MaxSliceLen, n); err != nil {
+		return
+	}
+	if n > math.MaxInt {
+		return pb, fmt.Errorf("invalid number of packed struct fields: %d", n)
+	}
+	if err = dec.checkTotal((n + 7) / 8); err != nil {
+		return
+	}
+	pb.n = int(n)
+	pb.b, err = dec.readAlloc((n + 7) / 8)
+	return
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/gob"
 	"fmt"
 	"reflect"
+	"strconv"
 	"sync"
 )
 
@@ -17,6 +18,51 @@ func (dte DecoderTypeError) Error() string {
 	return "expected " + dte.Expected + ", got " + dte.Actual.String()
 }
 
+// UnknownFieldError gets returned by a Decoder with DisallowUnknownFields set
+// when the input has a field that doesn't exist in the struct it's decoding into.
+type UnknownFieldError struct {
+	Type  reflect.Type
+	Field string
+}
+
+func (ufe *UnknownFieldError) Error() string {
+	return "unknown field " + strconv.Quote(ufe.Field) + " in " + ufe.Type.String()
+}
+
+// DecodeError wraps errors that happen while decoding the contents of a struct, map or slice,
+// it records where in the value and in the stream the error happened.
+type DecodeError struct {
+	Path   string // e.g. .Orders[3].Items["sku"].Price
+	Offset int64  // number of bytes read from the stream when the error happened
+	Err    error
+}
+
+func (de *DecodeError) Error() string {
+	msg := "error decoding"
+	if de.Path != "" {
+		msg += " " + de.Path
+	}
+	return msg + " at offset " + strconv.FormatInt(de.Offset, 10) + ": " + de.Err.Error()
+}
+
+func (de *DecodeError) Unwrap() error { return de.Err }
+
+// wrapPathError prepends seg to err's path, wrapping it in a *DecodeError if it isn't one already.
+func wrapPathError(d *Decoder, err error, seg string) error {
+	if de, ok := err.(*DecodeError); ok {
+		de.Path = seg + de.Path
+		return de
+	}
+	return &DecodeError{Path: seg, Offset: d.off, Err: err}
+}
+
+func keyPath(k reflect.Value) string {
+	if k.Kind() == reflect.String {
+		return "[" + strconv.Quote(k.String()) + "]"
+	}
+	return fmt.Sprintf("[%v]", k.Interface())
+}
+
 type decoderFunc func(dec *Decoder, v reflect.Value) error
 
 var (
@@ -39,6 +85,9 @@ func typeDecoder(t reflect.Type) (fn decoderFunc) {
 	decCache.Unlock()
 
 	fn = newTypeDecoder(t)
+	if t.Kind() == reflect.Ptr && t.Elem().Kind() != reflect.Ptr {
+		fn = refDecoder(fn)
+	}
 	decCache.Lock()
 	decCache.m[t] = fn
 	if k := t.Kind(); k == reflect.Struct || k == reflect.Ptr && t.Elem().Kind() == reflect.Struct {
@@ -112,8 +161,8 @@ func newTypeDecoder(t reflect.Type) decoderFunc {
 		return newStructDecoder(t)
 	case reflect.Ptr:
 		return newPtrDecoder(typeDecoder(t.Elem()), true)
-		// case reflect.Interface:
-		// 	return ifaceEncoder
+	case reflect.Interface:
+		return ifaceDecoder
 	}
 	return invalidDecoder
 }
@@ -178,12 +227,83 @@ func stringDecoder(d *Decoder, v reflect.Value) error {
 	return err
 }
 
+// quotedDecoder reads numbers and bools written as strings by quotedEncoder.
+func quotedDecoder(d *Decoder, v reflect.Value) error {
+	s, err := d.ReadString()
+	if err != nil {
+		return err
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		if i, err = strconv.ParseInt(s, 10, v.Type().Bits()); err == nil {
+			v.SetInt(i)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var u uint64
+		if u, err = strconv.ParseUint(s, 10, v.Type().Bits()); err == nil {
+			v.SetUint(u)
+		}
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		if f, err = strconv.ParseFloat(s, v.Type().Bits()); err == nil {
+			v.SetFloat(f)
+		}
+	case reflect.Bool:
+		var b bool
+		if b, err = strconv.ParseBool(s); err == nil {
+			v.SetBool(b)
+		}
+	}
+	return err
+}
+
 func bytesDecoder(d *Decoder, v reflect.Value) error {
 	b, err := d.ReadBytes()
 	v.SetBytes(b)
 	return err
 }
 
+// ifaceDecoder decodes Interface entries into a new value of the registered type,
+// otherwise it decodes into the value an interface holds if it's a non-nil pointer,
+// or stores a generic value (see Decoder.readValue) if the interface is empty.
+func ifaceDecoder(d *Decoder, v reflect.Value) error {
+	switch d.peekType() {
+	case Nil:
+		v.Set(reflect.Zero(v.Type()))
+		_, err := d.readType()
+		return err
+	case Interface:
+		iv, err := d.readIface()
+		if err != nil {
+			return err
+		}
+		if !iv.Type().AssignableTo(v.Type()) {
+			return fmt.Errorf("binny: %v is not assignable to %v", iv.Type(), v.Type())
+		}
+		v.Set(iv)
+		return nil
+	case BackRef:
+		return d.setBackRef(v)
+	}
+	if e := v.Elem(); e.Kind() == reflect.Ptr && !e.IsNil() {
+		return typeDecoder(e.Type())(d, e)
+	}
+	if v.NumMethod() > 0 {
+		return fmt.Errorf("can't decode into a non-empty interface: %v", v.Type())
+	}
+	iv, err := d.readValue()
+	if err != nil {
+		return err
+	}
+	if iv == nil {
+		v.Set(reflect.Zero(v.Type()))
+	} else {
+		v.Set(reflect.ValueOf(iv))
+	}
+	return nil
+}
+
 func invalidDecoder(d *Decoder, v reflect.Value) error {
 	return fmt.Errorf("%v is not supported", v.Type().String())
 }
@@ -197,31 +317,59 @@ func (sd sliceDecoder) decode(d *Decoder, v reflect.Value) error {
 		return err
 	}
 
-	ln, _, err := d.ReadUint()
-
+	ln, err := d.readLen("slice length", d.opts.MaxSliceLen)
 	if err != nil {
 		return err
 	}
+	if err = d.enter(); err != nil {
+		return err
+	}
+	defer d.leave()
 
+	// every element takes at least a byte, so a length the input can't have fails here rather than later
+	if ln > 0 {
+		if err = d.checkTotal(uint64(ln)); err != nil {
+			return err
+		}
+	}
+	if v.Kind() == reflect.Array && ln > v.Len() {
+		return fmt.Errorf("too many elements for %v: %d", v.Type(), ln)
+	}
 	if v.Kind() == reflect.Slice {
-		if ln := int(ln); v.Cap() < ln {
-			v.Set(reflect.MakeSlice(v.Type(), ln, ln))
-		} else {
+		switch {
+		case ln < 0: // it grows as the elements get read
+			v.SetLen(0)
+		case v.Cap() < ln:
+			v.Set(reflect.MakeSlice(v.Type(), 0, d.prealloc(ln, sd.t.Size())))
+		default:
 			v.SetLen(ln)
 			v.SetCap(ln)
 		}
 	}
 
 	dec := typeDecoder(sd.t)
-	for i := 0; i < int(ln); i++ {
+	for i := 0; ; i++ {
+		if ok, err := d.hasEntry(i, ln, "slice length", d.opts.MaxSliceLen); err != nil {
+			return err
+		} else if !ok {
+			break
+		}
+		if i >= v.Len() {
+			if v.Kind() != reflect.Slice {
+				return fmt.Errorf("too many elements for %v", v.Type())
+			}
+			v.Set(reflect.Append(v, reflect.Zero(sd.t)))
+		}
 		// this is a bug
 		if d.peekType() == Nil {
-			d.readType()
+			if _, err = d.readType(); err != nil {
+				return err
+			}
 			continue
 		}
 
 		if err = dec(d, v.Index(i)); err != nil {
-			return err
+			return wrapPathError(d, err, "["+strconv.Itoa(i)+"]")
 		}
 	}
 
@@ -241,35 +389,95 @@ type structDecoder struct {
 }
 
 func (sd structDecoder) decode(d *Decoder, v reflect.Value) error {
-	var (
-		flds   = cachedTypeFields(sd.t)
-		fields = make(map[string]*field, len(flds))
-	)
-	for i := range flds {
-		f := &flds[i] // need a pointer so when we later update fields .dec it'd get picked up.
-		fields[f.name] = f
+	flds := cachedTypeFields(sd.t)
+	switch ft, err := d.PeekType(); {
+	case err != nil:
+		return err
+	case ft == Nil || ft == EmptyStruct:
+		_, err = d.readType()
+		return err
+	case ft == PackedStruct:
+		d.readType()
+		return sd.decodePacked(d, v, flds)
 	}
 	if err := d.expectType(Struct); err != nil {
-		if err, ok := err.(DecoderTypeError); ok && err.Actual == Nil || err.Actual == EmptyStruct {
-			return nil
-		}
 		return err
 	}
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer d.leave()
+	fields := make(map[string]*field, len(flds))
+	for i := range flds {
+		f := &flds[i] // need a pointer so when we later update fields .dec it'd get picked up.
+		fields[f.name] = f
+	}
 	for {
-		n, err := d.ReadString()
+		if d.peekType() == EOV {
+			_, err := d.readType()
+			return err
+		}
+		n, err := d.readName()
 		if err != nil {
-			if err, ok := err.(DecoderTypeError); ok && err.Actual == EOV {
-				return nil
-			}
 			return err
 		}
-		if f, ok := fields[n]; ok {
-			fld := fieldByIndex(v, f.index, true)
-			if err := f.dec(d, fld); err != nil {
+		f, ok := fields[n]
+		if !ok {
+			if d.opts.DisallowUnknownFields {
+				return &UnknownFieldError{sd.t, n}
+			}
+			if err = d.Skip(); err != nil {
 				return err
 			}
+			continue
+		}
+		if err := f.decode(d, fieldByIndex(v, f.index, true)); err != nil {
+			return wrapPathError(d, err, "."+f.name)
+		}
+	}
+}
+
+func (sd structDecoder) decodePacked(d *Decoder, v reflect.Value, fields []field) error {
+	bitmap, err := d.readPackedBitmap()
+	if err != nil {
+		return err
+	}
+	if bitmap.n != len(fields) {
+		return fmt.Errorf("packed %v has %d fields, expected %d", sd.t, bitmap.n, len(fields))
+	}
+	if err = d.enter(); err != nil {
+		return err
+	}
+	defer d.leave()
+	for i := range fields {
+		if !bitmap.has(i) {
+			continue
+		}
+		f := &fields[i]
+		if err = f.decode(d, fieldByIndex(v, f.index, true)); err != nil {
+			return wrapPathError(d, err, "."+f.name)
+		}
+	}
+	return nil
+}
+
+// decode reads the field's value into v, a Nil entry resets it to its zero value.
+func (f *field) decode(d *Decoder, v reflect.Value) error {
+	if d.peekType() == Nil {
+		v.Set(reflect.Zero(v.Type()))
+		_, err := d.readType()
+		return err
+	}
+	if v.Kind() == reflect.Ptr && v.Type() != f.typ { // typeFields follows unnamed pointers
+		if ft := d.peekType(); ft == Ref || ft == BackRef {
+			return typeDecoder(v.Type())(d, v)
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(f.typ))
 		}
+		v = v.Elem()
 	}
+	return f.dec(d, v)
 }
 
 func newStructDecoder(t reflect.Type) decoderFunc {
@@ -285,31 +493,50 @@ func (md mapDecoder) decode(d *Decoder, v reflect.Value) error {
 	if err := d.expectType(Map); err != nil {
 		return err
 	}
-	ln, _, err := d.ReadUint()
-
+	ln, err := d.readLen("map length", d.opts.MaxMapLen)
 	if err != nil {
 		return err
 	}
+	if err = d.enter(); err != nil {
+		return err
+	}
+	defer d.leave()
 
+	if ln > 0 {
+		if err = d.checkTotal(uint64(ln)); err != nil {
+			return err
+		}
+	}
 	t := v.Type()
 	if v.IsNil() {
-		v.Set(reflect.MakeMapWithSize(t, int(ln)))
+		if ln < 0 {
+			v.Set(reflect.MakeMap(t))
+		} else {
+			v.Set(reflect.MakeMapWithSize(t, d.prealloc(ln, md.kt.Size()+md.vt.Size())))
+		}
 	}
 
 	kdec, vdec := typeDecoder(md.kt), typeDecoder(md.vt)
-	for i, kt, vt := 0, t.Key(), t.Elem(); i < int(ln); i++ {
+	for i, kt, vt := 0, t.Key(), t.Elem(); ; i++ {
+		if ok, err := d.hasEntry(i, ln, "map length", d.opts.MaxMapLen); err != nil {
+			return err
+		} else if !ok {
+			break
+		}
 		key := reflect.New(kt).Elem()
 		if err = kdec(d, key); err != nil {
-			return err
+			return wrapPathError(d, err, "")
 		}
 		if d.peekType() == Nil {
+			if _, err = d.readType(); err != nil {
+				return err
+			}
 			v.SetMapIndex(key, reflect.Zero(vt))
-			d.readType()
 			continue
 		}
 		val := reflect.New(vt).Elem()
 		if err = vdec(d, val); err != nil {
-			return err
+			return wrapPathError(d, err, keyPath(key))
 		}
 		v.SetMapIndex(key, val)
 	}
@@ -2,6 +2,9 @@ package binny
 
 import (
 	"bytes"
+	"errors"
+	"io"
+	"math"
 	"reflect"
 	"strconv"
 	"testing"
@@ -72,6 +75,256 @@ func TestDecoder(t *testing.T) {
 	}
 }
 
+func TestBytesDecoder(t *testing.T) {
+	for _, dt := range decoderTests {
+		b, err := Marshal(dt.in)
+		if err != nil {
+			t.Fatalf("%15s (encode): %v", dt.name, err)
+		}
+		exp := reflect.New(reflect.TypeOf(dt.in))
+		if err = NewDecoder(bytes.NewReader(b)).Decode(exp.Interface()); err != nil {
+			t.Fatalf("%15s (decode): %v", dt.name, err)
+		}
+		for _, noCopy := range []bool{false, true} {
+			val := reflect.New(reflect.TypeOf(dt.in))
+			if err = NewBytesDecoderOptions(b, DecoderOptions{NoCopy: noCopy}).Decode(val.Interface()); err != nil {
+				t.Fatalf("%15s (decode, NoCopy %v): %v", dt.name, noCopy, err)
+			}
+			if !reflect.DeepEqual(exp.Interface(), val.Interface()) {
+				t.Fatalf("%15s (NoCopy %v): failed\nexp: %+v\ngot: %+v", dt.name, noCopy, exp.Elem(), val.Elem())
+			}
+		}
+	}
+
+	b, _ := Marshal(struct{ S []byte }{[]byte("hello")})
+	var out struct{ S []byte }
+	if err := Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	b[bytes.Index(b, []byte("hello"))] = 'j'
+	if string(out.S) != "hello" {
+		t.Fatalf("Unmarshal didn't copy: %q", out.S)
+	}
+	if err := UnmarshalNoCopy(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	b[bytes.Index(b, []byte("jello"))] = 'h'
+	if string(out.S) != "hello" || cap(out.S) != len(out.S) {
+		t.Fatalf("UnmarshalNoCopy didn't alias the input: %q", out.S)
+	}
+
+	b, _ = Marshal(&benchVal)
+	for i := range b {
+		var s S
+		if err := Unmarshal(b[:i], &s); err == nil {
+			t.Fatalf("expected an error decoding %d of %d bytes", i, len(b))
+		}
+	}
+
+	b, _ = Marshal("hello")
+	var str string
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := UnmarshalNoCopy(b, &str); err != nil || str != "hello" {
+			t.Fatal(str, err)
+		}
+	})
+	if allocs > 0 {
+		t.Fatalf("expected no allocations, got %v", allocs)
+	}
+}
+
+func TestDecodeInterface(t *testing.T) {
+	in := struct {
+		S   *S
+		M   map[uint64]interface{}
+		SM  map[string]int
+		Arr []interface{}
+		B   []byte
+	}{
+		S:   &S{Str: "hi", I16: -300, F32: 1.5},
+		M:   uM{math.MaxUint64: "max"},
+		SM:  map[string]int{"a": 1},
+		Arr: []interface{}{nil, true, "x", 3.5},
+		B:   []byte("bytes"),
+	}
+	exp := map[string]interface{}{
+		"S":   map[string]interface{}{"Str": "hi", "I16": int64(-300), "F32": float32(1.5)},
+		"M":   map[interface{}]interface{}{uint64(math.MaxUint64): "max"},
+		"SM":  map[string]interface{}{"a": int64(1)},
+		"Arr": []interface{}{nil, true, "x", 3.5},
+		"B":   []byte("bytes"),
+	}
+	b, err := Marshal(&in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v interface{}
+	if err = Unmarshal(b, &v); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, exp) {
+		t.Fatalf("exp: %#v\ngot: %#v", exp, v)
+	}
+
+	// a non-nil pointer inside an interface gets decoded into.
+	if b, err = Marshal(struct{ S *S }{in.S}); err != nil {
+		t.Fatal(err)
+	}
+	var s S
+	if err = Unmarshal(b, &struct{ S interface{} }{&s}); err != nil {
+		t.Fatal(err)
+	}
+	if s.Str != "hi" || s.I16 != -300 {
+		t.Fatalf("unexpected value: %+v", s)
+	}
+}
+
+func TestSkip(t *testing.T) {
+	vals := []interface{}{
+		&benchVal,
+		SAll{I: -1, C64: 1, C128: 2, BS: []byte("x"), M: map[string]*SAll{"x": {U: 5}}, M2: map[MapKey]struct{}{{1, 2, 3}: {}}},
+		sM{{1, 2}: 3},
+		[]shape{circle{1}, &rect{2, 3}},
+		timeNow,
+		bigIntVal,
+		struct{}{},
+		[]interface{}{nil, true, false, -5.5, float32(1), int64(-1 << 40)},
+	}
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	for _, v := range vals {
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+		enc.WriteVarInt(-1000)
+		enc.WriteVarUint(1000)
+	}
+	enc.WriteString("last")
+	enc.Flush()
+
+	dec := NewDecoder(&buf)
+	for i := 0; i < len(vals)*3; i++ {
+		if err := dec.Skip(); err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+	}
+	if ft, err := dec.PeekType(); ft != String || err != nil {
+		t.Fatalf("expected String, got %v (%v)", ft, err)
+	}
+	var s string
+	if err := dec.Decode(&s); err != nil || s != "last" {
+		t.Fatalf("expected last, got %q (%v)", s, err)
+	}
+	if _, err := dec.PeekType(); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+	if err := dec.Skip(); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+}
+
+func TestUnknownFields(t *testing.T) {
+	type v1 struct {
+		A string
+		Z int
+	}
+	type v2 struct {
+		A   string
+		New map[string]*S
+		S   []S
+		Z   int
+	}
+	b, err := Marshal(&v2{"a", map[string]*S{"x": &benchVal}, []S{benchVal}, 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var o v1
+	if err = Unmarshal(b, &o); err != nil {
+		t.Fatal(err)
+	}
+	if o.A != "a" || o.Z != 42 {
+		t.Fatalf("unexpected value: %+v", o)
+	}
+
+	dec := NewDecoderOptions(bytes.NewReader(b), DecoderOptions{DisallowUnknownFields: true})
+	var ufe *UnknownFieldError
+	if err = dec.Decode(&o); !errors.As(err, &ufe) || ufe.Field != "New" {
+		t.Fatalf("expected an UnknownFieldError, got %v", err)
+	}
+}
+
+func TestDecodeErrorPath(t *testing.T) {
+	type item struct{ Price interface{} }
+	type order struct{ Items map[string]item }
+	in := struct{ Orders []order }{
+		Orders: []order{{}, {}, {}, {Items: map[string]item{"sku": {Price: "free"}}}},
+	}
+	b, err := Marshal(&in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out struct {
+		Orders []struct {
+			Items map[string]struct{ Price float64 }
+		}
+	}
+	err = Unmarshal(b, &out)
+	var (
+		de  *DecodeError
+		dte DecoderTypeError
+	)
+	if !errors.As(err, &de) || !errors.As(err, &dte) {
+		t.Fatalf("expected a DecodeError wrapping a DecoderTypeError, got %v", err)
+	}
+	if exp := `.Orders[3].Items["sku"].Price`; de.Path != exp {
+		t.Fatalf("expected %s, got %s", exp, de.Path)
+	}
+	if idx := int64(bytes.Index(b, []byte("free"))) - 2; de.Offset != idx {
+		t.Fatalf("expected offset %d, got %d", idx, de.Offset)
+	}
+}
+
+func TestDecodeTruncated(t *testing.T) {
+	for _, opts := range []EncoderOptions{{}, {Header: true, Compact: true}, {PackStructs: true, InternFieldNames: true}} {
+		var buf bytes.Buffer
+		enc := NewEncoderOptions(&buf, opts)
+		enc.Encode(&benchVal)
+		enc.Flush()
+		b := buf.Bytes()
+		hdrLen := 0
+		if opts.Header {
+			hdrLen = len(enc.header().appendTo(nil))
+		}
+		for i := 1; i < len(b); i++ {
+			if i == hdrLen {
+				continue // a stream with only a header ended cleanly
+			}
+			for _, v := range []interface{}{new(S), new(interface{})} {
+				if err := NewDecoder(bytes.NewReader(b[:i])).Decode(v); !errors.Is(err, io.ErrUnexpectedEOF) {
+					t.Fatalf("%+v: %T cut at %d/%d: expected io.ErrUnexpectedEOF, got %v", opts, v, i, len(b), err)
+				}
+			}
+		}
+
+		// io.EOF is only for the end of the stream
+		dec := NewBytesDecoder(b)
+		var s S
+		if err := dec.Decode(&s); err != nil {
+			t.Fatal(err)
+		}
+		if err := dec.Decode(&s); err != io.EOF {
+			t.Fatalf("expected io.EOF, got %v", err)
+		}
+	}
+	hdr := NewBytesEncoderOptions(nil, EncoderOptions{Header: true})
+	hdr.Flush()
+	var v interface{}
+	if err := NewBytesDecoder(hdr.Bytes()).Decode(&v); err != io.EOF {
+		t.Fatalf("expected io.EOF after the header, got %v", err)
+	}
+}
+
 func BenchmarkDecodeMap(b *testing.B) {
 	m := map[string]int{}
 	for i := 0; i < 1000; i++ {
@@ -126,6 +379,26 @@ func benchDecoderIface(b *testing.B, o interface{}) {
 	b.SetBytes(int64(len(bin)))
 }
 
+func benchDecoderPacked(b *testing.B, o interface{}) {
+	var buf bytes.Buffer
+	enc := NewEncoderOptions(&buf, EncoderOptions{PackStructs: true})
+	enc.Encode(o)
+	bin := buf.Bytes()
+	dec := NewDecoder(nil)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dec.Reset(bytes.NewReader(bin))
+		var s S
+		if err := dec.Decode(&s); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.SetBytes(int64(len(bin)))
+}
+
+func BenchmarkDecoderPackedBig(b *testing.B)   { benchDecoderPacked(b, &benchVal) }
+func BenchmarkDecoderPackedSmall(b *testing.B) { benchDecoderPacked(b, benchVal.S.S.S) }
+
 func BenchmarkUnmarshalerBig(b *testing.B) {
 	tmp := SI(benchVal)
 	benchDecoderIface(b, &tmp)
@@ -144,3 +417,25 @@ func BenchmarkDecoderBig(b *testing.B) {
 }
 
 func BenchmarkDecoderSmall(b *testing.B) { benchDecoder(b, benchVal.S.S.S) }
+
+func benchBytesDecoder(b *testing.B, o interface{}, noCopy bool) {
+	bin, _ := Marshal(o)
+	dec := NewBytesDecoderOptions(nil, DecoderOptions{NoCopy: noCopy})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dec.ResetBytes(bin)
+		var s S
+		if err := dec.Decode(&s); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.SetBytes(int64(len(bin)))
+}
+
+func BenchmarkBytesDecoderBig(b *testing.B)   { benchBytesDecoder(b, &benchVal, false) }
+func BenchmarkBytesDecoderSmall(b *testing.B) { benchBytesDecoder(b, benchVal.S.S.S, false) }
+func BenchmarkNoCopyDecoderBig(b *testing.B)  { benchBytesDecoder(b, &benchVal, true) }
+func BenchmarkNoCopyDecoderSmall(b *testing.B) {
+	benchBytesDecoder(b, benchVal.S.S.S, true)
+}
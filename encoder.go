@@ -3,6 +3,7 @@ package binny
 import (
 	"bufio"
 	"encoding"
+	"encoding/binary"
 	"encoding/gob"
 	"io"
 	"math"
@@ -12,6 +13,77 @@ import (
 
 const DefaultEncoderBufferSize = 4096
 
+const (
+	canonicalNaN32 = 0x7FC00000
+	canonicalNaN64 = 0x7FF8000000000000
+)
+
+// NativeEndian is the byte order of the current machine, binny used it for all fixed-width numbers before
+// switching to little-endian, use it as the ByteOrder option to read or write the legacy format.
+var NativeEndian = nativeEndian()
+
+func nativeEndian() binary.ByteOrder {
+	if v := uint16(1); *(*byte)(unsafe.Pointer(&v)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}
+
+// EncoderOptions are the options for NewEncoderOptions.
+type EncoderOptions struct {
+	// BufferSize is the size of the internal buffer, defaults to DefaultEncoderBufferSize, minimum is 24 bytes.
+	BufferSize int
+
+	// ByteOrder is used to write fixed-width numbers, defaults to binary.LittleEndian.
+	ByteOrder binary.ByteOrder
+
+	// Canonical makes the encoder produce identical bytes for equal values:
+	// map keys are sorted and all NaNs and zeros are written the same way.
+	Canonical bool
+
+	// InternFieldNames makes the encoder write every struct field name and registered interface name only once
+	// per stream, later occurrences are written as a small index into the stream's symbol table.
+	// The table is cleared on Reset.
+	InternFieldNames bool
+
+	// PackStructs makes the encoder write struct fields by their position instead of their name,
+	// which is a lot smaller and faster, but the decoder must use the exact same struct definition.
+	PackStructs bool
+
+	// KeepZeroFields makes the encoder write struct fields that have their zero value instead of omitting them,
+	// except for fields tagged with omitempty, nil maps, slices and pointers get written as Nil.
+	// Fields can also opt in individually with the keepzero tag option, e.g. `binny:"name,keepzero"`.
+	KeepZeroFields bool
+
+	// References makes the encoder write every pointer it sees only once per call to Encode, later occurrences
+	// of the same pointer are written as a back-reference, so the decoder rebuilds values that share data
+	// or even point to themselves. Without it shared data gets duplicated and cycles return a *CycleError.
+	References bool
+
+	// Compact makes the encoder write integers as a VarInt or VarUint whenever that's smaller than
+	// the fixed-width entry, and lengths as bare varints. The decoder has to be created with
+	// DecoderOptions.Compact unless the stream has a Header, which uses FormatVersion 2 for it.
+	Compact bool
+
+	// MaxDepth is the max nesting of structs, maps, slices and pointers, a deeper value returns a *LimitError.
+	// Regardless of it, once the nesting gets deep the encoder starts checking for cycles and returns a *CycleError.
+	MaxDepth int
+
+	// Header makes the encoder start the stream with a Header describing the format version and options,
+	// it gets written on creation and on every Reset.
+	Header bool
+
+	// Compression is the ID of a registered Codec, e.g. CodecFlate, that compresses everything after the Header
+	// in independent blocks of up to BlockSize bytes, the Header is always written then. A block ends on every
+	// Flush, including the one after each Encode unless NoAutoFlushOnEncode is set, or on FlushBlock.
+	// The decoder detects compressed streams on its own. It's ignored when writing to a byte slice.
+	Compression uint8
+
+	// BlockSize is the max size of a block before compression, defaults to DefaultBlockSize, max is 16MiB.
+	// It replaces BufferSize when Compression is set.
+	BlockSize int
+}
+
 // Marshaler is the interface implemented by objects that can marshal themselves into a binary representation.
 // Implementing this bypasses reflection and is generally faster but not nessecery optimized.
 type Marshaler interface {
@@ -19,7 +91,24 @@ type Marshaler interface {
 }
 
 type Encoder struct {
-	w *bufio.Writer
+	w      *bufio.Writer
+	dst    []byte       // the output when writing directly to a byte slice, w is nil then
+	blocks *blockWriter // what w writes to with EncoderOptions.Compression
+	order  binary.ByteOrder
+	opts   EncoderOptions
+
+	syms map[string]uint64
+
+	depth   int
+	ptrSeen map[cycleKey]struct{}
+
+	refs   map[cycleKey]uint64 // the pointers written so far, see EncoderOptions.References
+	nested int                 // Encode calls in progress, references are scoped to the outermost one
+
+	open int    // slices and maps started with BeginSlice or BeginMap
+	toks []Type // structs, maps and slices started with WriteToken
+
+	buf [16]byte
 
 	NoAutoFlushOnEncode bool // Do not auto flush after calling .Encode.
 }
@@ -31,22 +120,129 @@ func NewEncoder(w io.Writer) *Encoder {
 
 // NewEncoder returns a new encoder with the specific buffer size, minimum is 24 bytes.
 func NewEncoderSize(w io.Writer, sz int) *Encoder {
-	if sz < 24 {
-		sz = 24
+	return NewEncoderOptions(w, EncoderOptions{BufferSize: sz})
+}
+
+// NewEncoderOptions returns a new encoder with the specified options.
+func NewEncoderOptions(w io.Writer, opts EncoderOptions) *Encoder {
+	enc := newEncoder(opts)
+	enc.w = bufio.NewWriterSize(enc.output(w), enc.bufferSize())
+	if opts.Header || enc.compressed() {
+		enc.writeHeader()
+	}
+	return enc
+}
+
+// NewBytesEncoder is an alias for NewBytesEncoderOptions(dst, EncoderOptions{})
+func NewBytesEncoder(dst []byte) *Encoder {
+	return NewBytesEncoderOptions(dst, EncoderOptions{})
+}
+
+// NewBytesEncoderOptions returns a new encoder that appends directly to dst, without any buffering,
+// use Bytes to get the result. BufferSize is ignored.
+func NewBytesEncoderOptions(dst []byte, opts EncoderOptions) *Encoder {
+	enc := newEncoder(opts)
+	enc.dst = dst
+	if opts.Header {
+		enc.writeHeader()
+	}
+	return enc
+}
+
+func newEncoder(opts EncoderOptions) *Encoder {
+	if opts.BufferSize == 0 {
+		opts.BufferSize = DefaultEncoderBufferSize
+	}
+	if opts.BufferSize < 24 {
+		opts.BufferSize = 24
+	}
+	if opts.ByteOrder == nil {
+		opts.ByteOrder = binary.LittleEndian
+	}
+	if opts.BlockSize <= 0 {
+		opts.BlockSize = DefaultBlockSize
+	}
+	if opts.BlockSize > maxBlockSize {
+		opts.BlockSize = maxBlockSize
 	}
 	return &Encoder{
-		w: bufio.NewWriterSize(w, sz),
+		order: opts.ByteOrder,
+		opts:  opts,
 	}
 }
 
+// Options returns the options the encoder was created with.
+func (enc *Encoder) Options() EncoderOptions {
+	return enc.opts
+}
+
+func (enc *Encoder) bufferSize() int {
+	if enc.opts.Compression != 0 {
+		return enc.opts.BlockSize
+	}
+	return enc.opts.BufferSize
+}
+
 // Reset discards any unflushed buffered data, clears any error, and
 // resets b to write its output to w.
 func (enc *Encoder) Reset(w io.Writer) {
-	enc.w.Reset(w)
+	if enc.w == nil {
+		enc.w = bufio.NewWriterSize(enc.output(w), enc.bufferSize())
+	} else {
+		enc.w.Reset(enc.output(w))
+	}
+	enc.dst = nil
+	enc.reset()
+}
+
+// ResetBytes resets all state like Reset, and switches the encoder to append directly to dst.
+func (enc *Encoder) ResetBytes(dst []byte) {
+	enc.w = nil
+	enc.dst = dst
+	enc.reset()
+}
+
+// Bytes returns dst with everything written since the encoder was created or reset with it,
+// it's always nil for an encoder writing to an io.Writer.
+func (enc *Encoder) Bytes() []byte {
+	return enc.dst
+}
+
+func (enc *Encoder) reset() {
+	enc.syms = nil
+	enc.depth, enc.ptrSeen = 0, nil
+	enc.refs, enc.nested = nil, 0
+	enc.open, enc.toks = 0, enc.toks[:0]
+	if enc.opts.Header || enc.compressed() {
+		enc.writeHeader()
+	}
 }
 
 func (enc *Encoder) writeType(t Type) error {
-	return enc.w.WriteByte(byte(t))
+	return enc.writeByte(byte(t))
+}
+
+// writeByte, writeString and Write are the only functions that should touch the output.
+func (enc *Encoder) writeByte(b byte) error {
+	if enc.w == nil {
+		enc.dst = append(enc.dst, b)
+		return nil
+	}
+	return enc.w.WriteByte(b)
+}
+
+func (enc *Encoder) writeString(s string) error {
+	if enc.w == nil {
+		enc.dst = append(enc.dst, s...)
+		return nil
+	}
+	_, err := enc.w.WriteString(s)
+	return err
+}
+
+// WriteNil writes a Nil entry, which decodes as the zero value.
+func (enc *Encoder) WriteNil() error {
+	return enc.writeType(Nil)
 }
 
 func (enc *Encoder) WriteVarUint(x uint64) error {
@@ -56,10 +252,10 @@ func (enc *Encoder) WriteVarUint(x uint64) error {
 
 func (enc *Encoder) writeVarUint(x uint64) error {
 	for x >= 0x80 {
-		enc.w.WriteByte(byte(x) | 0x80)
+		enc.writeByte(byte(x) | 0x80)
 		x >>= 7
 	}
-	return enc.w.WriteByte(byte(x))
+	return enc.writeByte(byte(x))
 }
 
 func (enc *Encoder) WriteVarInt(x int64) error {
@@ -68,18 +264,72 @@ func (enc *Encoder) WriteVarInt(x int64) error {
 }
 
 func (enc *Encoder) writeVarInt(x int64) error {
+	return enc.writeVarUint(zigzag(x))
+}
+
+func zigzag(x int64) uint64 {
 	ux := uint64(x) << 1
 	if x < 0 {
 		ux = ^ux
 	}
-	return enc.writeVarUint(ux)
+	return ux
+}
+
+func uvarintLen(x uint64) int {
+	n := 1
+	for ; x >= 0x80; x >>= 7 {
+		n++
+	}
+	return n
+}
+
+// uintSize and intSize return the number of bytes WriteUint and WriteInt use for v without the Type.
+func uintSize(v uint64) int {
+	switch {
+	case v <= math.MaxUint8:
+		return 1
+	case v <= math.MaxUint16:
+		return 2
+	case v <= math.MaxUint32:
+		return 4
+	}
+	return 8
+}
+
+func intSize(v int64) int {
+	switch {
+	case v >= math.MinInt8 && v <= math.MaxInt8:
+		return 1
+	case v >= math.MinInt16 && v <= math.MaxInt16:
+		return 2
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		return 4
+	}
+	return 8
 }
 
 func (enc *Encoder) WriteString(v string) error {
 	enc.writeType(String)
 	enc.writeLen(len(v))
-	_, err := enc.w.WriteString(v)
-	return err
+	return enc.writeString(v)
+}
+
+// writeName writes a struct field or interface name, as a String or as a symbol if InternFieldNames is set.
+func (enc *Encoder) writeName(name string) error {
+	if !enc.opts.InternFieldNames {
+		return enc.WriteString(name)
+	}
+	if id, ok := enc.syms[name]; ok {
+		enc.writeType(SymbolRef)
+		return enc.writeVarUint(id)
+	}
+	if enc.syms == nil {
+		enc.syms = map[string]uint64{}
+	}
+	enc.syms[name] = uint64(len(enc.syms))
+	enc.writeType(Symbol)
+	enc.writeLen(len(name))
+	return enc.writeString(name)
 }
 
 func (enc *Encoder) WriteBytes(v []byte) error {
@@ -90,8 +340,42 @@ func (enc *Encoder) WriteBytes(v []byte) error {
 }
 
 func (enc *Encoder) Encode(v interface{}) (err error) {
+	return enc.encodeTop(v, false)
+}
+
+// EncodeElem writes the value p points to, it's the same as Encode(p) unless EncoderOptions.References is set,
+// then p itself isn't tracked, so it's never written as a reference. It's meant for generated code.
+func (enc *Encoder) EncodeElem(p interface{}) error {
+	return enc.encodeTop(p, true)
+}
+
+func (enc *Encoder) encodeTop(v interface{}, elem bool) (err error) {
 	oldNoFlush := enc.NoAutoFlushOnEncode
 	enc.NoAutoFlushOnEncode = true
+	enc.nested++
+	err = enc.encode(v, elem)
+	if enc.nested--; enc.nested == 0 && len(enc.refs) > 0 {
+		enc.refs = nil
+	}
+	enc.NoAutoFlushOnEncode = oldNoFlush
+	if !oldNoFlush {
+		if ferr := enc.Flush(); err == nil {
+			err = ferr
+		}
+	}
+	return err
+}
+
+func (enc *Encoder) encode(v interface{}, elem bool) (err error) {
+	if enc.opts.References {
+		// pointers have to go through typeEncoder to be tracked, even if they implement Marshaler
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && !rv.IsNil() {
+			if elem {
+				rv = rv.Elem()
+			}
+			return enc.encodeValue(rv)
+		}
+	}
 	switch v := v.(type) {
 	case Marshaler:
 		err = v.MarshalBinny(enc)
@@ -136,10 +420,6 @@ func (enc *Encoder) Encode(v interface{}) (err error) {
 	default:
 		err = enc.encodeValue(reflect.ValueOf(v))
 	}
-	enc.NoAutoFlushOnEncode = oldNoFlush
-	if !oldNoFlush {
-		enc.Flush()
-	}
 	return err
 }
 
@@ -150,6 +430,9 @@ func (enc *Encoder) encodeValue(v reflect.Value) error {
 
 // WriteUint writes v in the smallest possible native size
 func (enc *Encoder) WriteUint(v uint64) error {
+	if enc.opts.Compact && uvarintLen(v) < uintSize(v) {
+		return enc.WriteVarUint(v)
+	}
 	if v <= math.MaxUint8 {
 		return enc.WriteUint8(uint8(v))
 	}
@@ -162,19 +445,18 @@ func (enc *Encoder) WriteUint(v uint64) error {
 	return enc.WriteUint64(v)
 }
 
-// WriteInt writes u in the smallest possible native size
+// WriteInt writes v in the smallest possible native size
 func (enc *Encoder) WriteInt(v int64) error {
-	u := v
-	if u < 0 {
-		u = -u
+	if enc.opts.Compact && uvarintLen(zigzag(v)) < intSize(v) {
+		return enc.WriteVarInt(v)
 	}
-	if u <= math.MaxInt8 {
+	if v >= math.MinInt8 && v <= math.MaxInt8 {
 		return enc.WriteInt8(int8(v))
 	}
-	if u <= math.MaxInt16 {
+	if v >= math.MinInt16 && v <= math.MaxInt16 {
 		return enc.WriteInt16(int16(v))
 	}
-	if u <= math.MaxInt32 {
+	if v >= math.MinInt32 && v <= math.MaxInt32 {
 		return enc.WriteInt32(int32(v))
 	}
 	return enc.WriteInt64(v)
@@ -190,12 +472,12 @@ func (enc *Encoder) WriteBool(v bool) error {
 
 func (enc *Encoder) WriteInt8(v int8) (err error) {
 	enc.writeType(Int8)
-	return enc.w.WriteByte(byte(v))
+	return enc.writeByte(byte(v))
 }
 
 func (enc *Encoder) WriteUint8(v uint8) (err error) {
 	enc.writeType(Uint8)
-	return enc.w.WriteByte(v)
+	return enc.writeByte(v)
 }
 
 func (enc *Encoder) WriteByte(v byte) (err error) {
@@ -204,64 +486,112 @@ func (enc *Encoder) WriteByte(v byte) (err error) {
 
 func (enc *Encoder) WriteUint16(v uint16) error {
 	enc.writeType(Uint16)
-	_, err := enc.Write((*[2]byte)(unsafe.Pointer(&v))[:2:2])
+	buf := enc.buf[:2]
+	enc.order.PutUint16(buf, v)
+	_, err := enc.Write(buf)
 	return err
 }
 
 func (enc *Encoder) WriteInt16(v int16) error {
 	enc.writeType(Int16)
-	_, err := enc.Write((*[2]byte)(unsafe.Pointer(&v))[:2:2])
+	buf := enc.buf[:2]
+	enc.order.PutUint16(buf, uint16(v))
+	_, err := enc.Write(buf)
 	return err
 }
 
 func (enc *Encoder) WriteUint32(v uint32) error {
 	enc.writeType(Uint32)
-	_, err := enc.Write((*[4]byte)(unsafe.Pointer(&v))[:4:4])
+	buf := enc.buf[:4]
+	enc.order.PutUint32(buf, v)
+	_, err := enc.Write(buf)
 	return err
 }
 
 func (enc *Encoder) WriteInt32(v int32) error {
 	enc.writeType(Int32)
-	_, err := enc.Write((*[4]byte)(unsafe.Pointer(&v))[:4:4])
+	buf := enc.buf[:4]
+	enc.order.PutUint32(buf, uint32(v))
+	_, err := enc.Write(buf)
 	return err
 }
 
 func (enc *Encoder) WriteUint64(v uint64) error {
 	enc.writeType(Uint64)
-	_, err := enc.Write((*[8]byte)(unsafe.Pointer(&v))[:8:8])
+	buf := enc.buf[:8]
+	enc.order.PutUint64(buf, v)
+	_, err := enc.Write(buf)
 	return err
 }
 
 func (enc *Encoder) WriteInt64(v int64) error {
 	enc.writeType(Int64)
-	_, err := enc.Write((*[8]byte)(unsafe.Pointer(&v))[:8:8])
+	buf := enc.buf[:8]
+	enc.order.PutUint64(buf, uint64(v))
+	_, err := enc.Write(buf)
 	return err
 }
 
 func (enc *Encoder) WriteFloat32(v float32) error {
 	enc.writeType(Float32)
-	_, err := enc.Write((*[4]byte)(unsafe.Pointer(&v))[:4:4])
+	buf := enc.buf[:4]
+	enc.order.PutUint32(buf, enc.float32bits(v))
+	_, err := enc.Write(buf)
 	return err
 }
 
 func (enc *Encoder) WriteFloat64(v float64) error {
 	enc.writeType(Float64)
-	_, err := enc.Write((*[8]byte)(unsafe.Pointer(&v))[:8:8])
+	buf := enc.buf[:8]
+	enc.order.PutUint64(buf, enc.float64bits(v))
+	_, err := enc.Write(buf)
 	return err
 }
 
 func (enc *Encoder) WriteComplex64(v complex64) error {
 	enc.writeType(Complex64)
-	_, err := enc.Write((*[8]byte)(unsafe.Pointer(&v))[:8:8])
+	buf := enc.buf[:8]
+	enc.order.PutUint32(buf, enc.float32bits(real(v)))
+	enc.order.PutUint32(buf[4:], enc.float32bits(imag(v)))
+	_, err := enc.Write(buf)
 	return err
 }
 
 func (enc *Encoder) WriteComplex128(v complex128) error {
 	enc.writeType(Complex128)
-	_, err := enc.Write((*[16]byte)(unsafe.Pointer(&v))[:16:16])
+	buf := enc.buf[:16]
+	enc.order.PutUint64(buf, enc.float64bits(real(v)))
+	enc.order.PutUint64(buf[8:], enc.float64bits(imag(v)))
+	_, err := enc.Write(buf)
 	return err
 }
 
+// float32bits returns the bits of v, in canonical mode all NaNs are the same NaN and -0 is 0.
+func (enc *Encoder) float32bits(v float32) uint32 {
+	if enc.opts.Canonical {
+		if v != v {
+			return canonicalNaN32
+		}
+		if v == 0 {
+			return 0
+		}
+	}
+	return math.Float32bits(v)
+}
+
+// float64bits returns the bits of v, in canonical mode all NaNs are the same NaN and -0 is 0.
+func (enc *Encoder) float64bits(v float64) uint64 {
+	if enc.opts.Canonical {
+		if v != v {
+			return canonicalNaN64
+		}
+		if v == 0 {
+			return 0
+		}
+	}
+	return math.Float64bits(v)
+}
+
 func (enc *Encoder) WriteBinary(v encoding.BinaryMarshaler) error {
 	b, err := v.MarshalBinary()
 	if err != nil {
@@ -287,24 +617,64 @@ func (enc *Encoder) WriteGob(v gob.GobEncoder) error {
 // Write writes the contents of p into the buffer.
 // It allows the Encoder to be used as a regular io.Writer since it takes control of the original w.
 func (enc *Encoder) Write(p []byte) (n int, err error) {
+	if enc.w == nil {
+		enc.dst = append(enc.dst, p...)
+		return len(p), nil
+	}
 	return enc.w.Write(p)
 }
 
-// Flush writes any buffered data to the underlying io.Writer.
+// Flush writes any buffered data to the underlying io.Writer, it's a no-op when writing to a byte slice.
 func (enc *Encoder) Flush() error {
+	if enc.w == nil {
+		return nil
+	}
 	return enc.w.Flush()
 }
 
+// writeLen writes the length of a String, ByteSlice, Binary, Gob or Symbol,
+// as a Uint entry or as a bare varint with EncoderOptions.Compact.
 func (enc *Encoder) writeLen(ln int) error {
+	if enc.opts.Compact {
+		return enc.writeVarUint(uint64(ln))
+	}
 	return enc.WriteUint(uint64(ln))
 }
 
-// Marshal is an alias for (sync.Pool'ed) NewEncoder(bytes.NewBuffer()).Encode(v)
+// writeEntries writes the length of a Map or Slice, or that it's unknown if ln is -1.
+//
+//	[len] or [Nil], with EncoderOptions.Compact [varuint(len+1)] or [varuint(0)]
+func (enc *Encoder) writeEntries(ln int) error {
+	if enc.opts.Compact {
+		return enc.writeVarUint(uint64(ln + 1))
+	}
+	if ln < 0 {
+		return enc.writeType(Nil)
+	}
+	return enc.WriteUint(uint64(ln))
+}
+
+// Marshal is an alias for (sync.Pool'ed) NewBytesEncoder(nil).Encode(v)
 func Marshal(v interface{}) ([]byte, error) {
-	enc := getEncBuffer()
-	err := enc.e.Encode(v)
-	enc.e.Flush()
-	b := []byte(enc.b.String()) // have to create a copy sadly :(
-	putEncBuffer(enc)
+	eb := getEncBuffer()
+	err := eb.e.Encode(v)
+	b := append([]byte(nil), eb.e.Bytes()...) // the pooled buffer gets reused, so it has to be copied
+	putEncBuffer(eb)
 	return b, err
 }
+
+// Append appends the encoding of v to dst and returns the extended slice, on error dst is returned unchanged.
+// Unlike Marshal it doesn't allocate anything if dst has enough capacity.
+func Append(dst []byte, v interface{}) ([]byte, error) {
+	eb := getEncBuffer()
+	own := eb.e.Bytes()
+	eb.e.ResetBytes(dst)
+	err := eb.e.Encode(v)
+	b := eb.e.Bytes()
+	eb.e.ResetBytes(own) // don't let the pool hold on to dst
+	putEncBuffer(eb)
+	if err != nil {
+		return dst, err
+	}
+	return b, nil
+}
@@ -1,9 +1,12 @@
 package binny
 
 import (
+	"bytes"
 	"encoding"
 	"encoding/gob"
 	"reflect"
+	"sort"
+	"strconv"
 	"sync"
 )
 
@@ -30,6 +33,9 @@ func typeEncoder(t reflect.Type) (fn encoderFunc) {
 	encCache.Unlock()
 
 	fn = newTypeEncoder(t, true)
+	if t.Kind() == reflect.Ptr && t.Elem().Kind() != reflect.Ptr {
+		fn = refEncoder(fn)
+	}
 	encCache.Lock()
 	encCache.m[t] = fn
 	if k := t.Kind(); k == reflect.Struct || k == reflect.Ptr && t.Elem().Kind() == reflect.Struct {
@@ -119,6 +125,22 @@ func stringEncoder(e *Encoder, v reflect.Value) error {
 	return e.WriteString(v.String())
 }
 
+// quotedEncoder writes numbers and bools as strings, for fields tagged with the string option.
+func quotedEncoder(e *Encoder, v reflect.Value) error {
+	var s string
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s = strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		s = strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		s = strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits())
+	case reflect.Bool:
+		s = strconv.FormatBool(v.Bool())
+	}
+	return e.WriteString(s)
+}
+
 func bytesEncoder(e *Encoder, v reflect.Value) error {
 	return e.WriteBytes(v.Bytes())
 }
@@ -157,7 +179,14 @@ func emptyStructEncoder(e *Encoder, v reflect.Value) error {
 }
 
 func ifaceEncoder(e *Encoder, v reflect.Value) error {
+	if v.IsNil() {
+		return e.writeType(Nil)
+	}
 	v = v.Elem()
+	if name, ok := registeredName(v.Type()); ok {
+		e.writeType(Interface)
+		e.writeName(name)
+	}
 	encFunc := typeEncoder(v.Type())
 	return encFunc(e, v)
 }
@@ -170,9 +199,13 @@ type sliceEncoder struct {
 }
 
 func (se sliceEncoder) encode(e *Encoder, v reflect.Value) (err error) {
+	if err = e.enter(v); err != nil {
+		return
+	}
+	defer e.leave(v)
 	ln := v.Len()
 	e.writeType(Slice)
-	e.writeLen(ln)
+	e.writeEntries(ln)
 	enc := typeEncoder(se.t)
 	for i := 0; i < ln; i++ {
 		vv := v.Index(i)
@@ -203,11 +236,19 @@ type mapEncoder struct {
 }
 
 func (me mapEncoder) encode(e *Encoder, v reflect.Value) (err error) {
+	if err = e.enter(v); err != nil {
+		return
+	}
+	defer e.leave(v)
 	kenc, venc := typeEncoder(me.kt), typeEncoder(me.vt)
 	keys := v.MapKeys()
+	if e.opts.Canonical {
+		if err = sortKeys(e, kenc, keys); err != nil {
+			return
+		}
+	}
 	e.writeType(Map)
-	e.writeLen(len(keys))
-	//sort.Sort(byString(keys))
+	e.writeEntries(len(keys))
 	for _, k := range keys {
 		vv := v.MapIndex(k)
 		if err = kenc(e, k); err != nil {
@@ -231,6 +272,47 @@ func (bs byString) Len() int           { return len(bs) }
 func (bs byString) Swap(i, j int)      { bs[i], bs[j] = bs[j], bs[i] }
 func (bs byString) Less(i, j int) bool { return bs[i].String() < bs[j].String() }
 
+// byEncoded sorts keys by their encoded form, which works for any key type.
+type byEncoded struct {
+	keys []reflect.Value
+	b    [][]byte
+}
+
+func (be byEncoded) Len() int { return len(be.keys) }
+func (be byEncoded) Swap(i, j int) {
+	be.keys[i], be.keys[j] = be.keys[j], be.keys[i]
+	be.b[i], be.b[j] = be.b[j], be.b[i]
+}
+func (be byEncoded) Less(i, j int) bool { return bytes.Compare(be.b[i], be.b[j]) < 0 }
+
+func sortKeys(e *Encoder, kenc encoderFunc, keys []reflect.Value) error {
+	if len(keys) < 2 {
+		return nil
+	}
+	if keys[0].Kind() == reflect.String {
+		sort.Sort(byString(keys))
+		return nil
+	}
+
+	eb := getEncBufferFor(e)
+	defer putEncBuffer(eb)
+	offs := make([]int, len(keys)+1)
+	for i, k := range keys {
+		if err := kenc(eb.e, k); err != nil {
+			return err
+		}
+		offs[i+1] = len(eb.e.Bytes())
+	}
+
+	be := byEncoded{keys, make([][]byte, len(keys))}
+	b := eb.e.Bytes()
+	for i := range keys {
+		be.b[i] = b[offs[i]:offs[i+1]]
+	}
+	sort.Sort(be)
+	return nil
+}
+
 func newMapEncoder(t reflect.Type) encoderFunc {
 	typeEncoder(t.Key()) // cache the type
 	typeEncoder(t.Elem())
@@ -247,16 +329,22 @@ func (se structEncoder) encode(e *Encoder, v reflect.Value) (err error) {
 	if len(fields) == 0 {
 		return e.writeType(EmptyStruct)
 	}
+	if err = e.enter(v); err != nil {
+		return
+	}
+	defer e.leave(v)
+	if e.opts.PackStructs {
+		return se.encodePacked(e, v, fields)
+	}
 	e.writeType(Struct)
 	for i := range fields {
 		tf := &fields[i]
-		vf := indirect(fieldByIndex(v, tf.index, false))
-		if !vf.IsValid() || tf.zero(vf) {
+		vf, ok := tf.value(v, e.opts.KeepZeroFields)
+		if !ok {
 			continue
 		}
-		e.WriteString(tf.name)
-		err = tf.enc(e, vf)
-		if err != nil {
+		e.writeName(tf.name)
+		if err = tf.encode(e, v, vf); err != nil {
 			return
 		}
 	}
@@ -264,6 +352,45 @@ func (se structEncoder) encode(e *Encoder, v reflect.Value) (err error) {
 	return
 }
 
+// encodePacked writes the fields by their position instead of their name,
+// with a bitmap of which fields are present.
+func (se structEncoder) encodePacked(e *Encoder, v reflect.Value, fields []field) (err error) {
+	e.writeType(PackedStruct)
+	e.writeVarUint(uint64(len(fields)))
+	var bits byte
+	for i := range fields {
+		if _, ok := fields[i].value(v, e.opts.KeepZeroFields); ok {
+			bits |= 1 << (i % 8)
+		}
+		if i%8 == 7 || i == len(fields)-1 {
+			e.writeByte(bits)
+			bits = 0
+		}
+	}
+	for i := range fields {
+		tf := &fields[i]
+		vf, ok := tf.value(v, e.opts.KeepZeroFields)
+		if !ok {
+			continue
+		}
+		if err = tf.encode(e, v, vf); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// encode writes v, which is the result of f.value on the struct sv, invalid values get written as Nil.
+func (f *field) encode(e *Encoder, sv, v reflect.Value) error {
+	if !v.IsValid() {
+		return e.writeType(Nil)
+	}
+	if f.ptr != nil && e.opts.References { // write the pointer itself so it can be shared
+		return typeEncoder(f.ptr)(e, fieldByIndex(sv, f.index, false))
+	}
+	return f.enc(e, v)
+}
+
 func newStructEncoder(t reflect.Type) encoderFunc {
 	se := structEncoder{t}
 	return se.encode
@@ -271,6 +398,13 @@ func newStructEncoder(t reflect.Type) encoderFunc {
 
 func ptrEncoder(fn encoderFunc) encoderFunc {
 	return func(e *Encoder, v reflect.Value) error {
+		if v.IsNil() {
+			return e.writeType(Nil)
+		}
+		if err := e.enter(v); err != nil {
+			return err
+		}
+		defer e.leave(v)
 		return fn(e, v.Elem())
 	}
 }
@@ -2,8 +2,11 @@ package binny
 
 import (
 	"bytes"
+	"encoding/binary"
+	"errors"
 	"math"
 	"math/big"
+	"reflect"
 	"strconv"
 	"strings"
 	"testing"
@@ -72,6 +75,46 @@ func TestEncoder(t *testing.T) {
 	}
 }
 
+func TestAppend(t *testing.T) {
+	prefix := []byte("prefix")
+	for _, et := range encoderTests {
+		b, err := Append(prefix, et.in)
+		if err != nil {
+			t.Fatalf("%s: %v", et.name, err)
+		}
+		if !bytes.Equal(b[:len(prefix)], prefix) || !bytes.Equal(b[len(prefix):], et.exp.b) {
+			t.Fatalf("%10s: failed\nexp: %v\ngot: %v", et.name, et.exp.b, b)
+		}
+	}
+
+	if b, err := Append(prefix, make(chan int)); err != ErrUnsupportedType || !bytes.Equal(b, prefix) {
+		t.Fatalf("expected ErrUnsupportedType and dst unchanged, got %v, %q", err, b)
+	}
+
+	in := S{I8: 1, U16: 2, Str: "hello"}
+	dst := make([]byte, 0, 256)
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := Append(dst, &in); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs > 0 {
+		t.Fatalf("expected no allocations, got %v", allocs)
+	}
+
+	// a bytes encoder writes exactly what a buffered one does
+	var buf bytes.Buffer
+	opts := EncoderOptions{Header: true, InternFieldNames: true}
+	enc, benc := NewEncoderOptions(&buf, opts), NewBytesEncoderOptions(nil, opts)
+	for i := 0; i < 2; i++ {
+		enc.Encode(&benchVal)
+		benc.Encode(&benchVal)
+	}
+	if !bytes.Equal(buf.Bytes(), benc.Bytes()) {
+		t.Fatalf("bytes encoder output differs:\nexp: %v\ngot: %v", buf.Bytes(), benc.Bytes())
+	}
+}
+
 func TestMarshalBinny(t *testing.T) {
 	var s sKM
 	var v uint64
@@ -87,6 +130,320 @@ func TestMarshalBinny(t *testing.T) {
 	}
 }
 
+func TestByteOrder(t *testing.T) {
+	in := SAll{I16: -2, U32: 0x01020304, F64: math.Pi, C64: complex(1, -2), C128: complex(-3, 4)}
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian, NativeEndian} {
+		var buf bytes.Buffer
+		enc := NewEncoderOptions(&buf, EncoderOptions{ByteOrder: order})
+		if err := enc.Encode(&in); err != nil {
+			t.Fatal(err)
+		}
+		var out SAll
+		if err := NewDecoderOptions(&buf, DecoderOptions{ByteOrder: order}).Decode(&out); err != nil {
+			t.Fatalf("%v: %v", order, err)
+		}
+		if in.NotEq(t, &out) {
+			t.Fatalf("%v: failed\nexp: %+v\ngot: %+v", order, in, out)
+		}
+	}
+
+	b, err := Marshal(uint32(0x01020304))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := []byte{byte(Uint32), 4, 3, 2, 1}; !bytes.Equal(b, exp) {
+		t.Fatalf("expected little-endian %v, got %v", exp, b)
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	type V struct {
+		M  map[string]int
+		SM sM
+		IM map[int]float64
+		FM map[float32]bool
+	}
+	mk := func(nan float64) *V {
+		v := &V{M: map[string]int{}, SM: sM{}, IM: map[int]float64{}, FM: map[float32]bool{}}
+		for i := 0; i < 64; i++ {
+			v.M[strconv.Itoa(i)] = i
+			v.SM[sK{i, -i}] = i
+			v.IM[i*1000] = -float64(i) // -0 for 0
+			v.FM[float32(i)/3] = true
+		}
+		v.IM[-1] = nan
+		return v
+	}
+
+	var exp []byte
+	for i := 0; i < 10; i++ {
+		var buf bytes.Buffer
+		enc := NewEncoderOptions(&buf, EncoderOptions{Canonical: true})
+		nan := math.NaN()
+		if i%2 == 1 {
+			nan = math.Float64frombits(0x7FF0000000000F00)
+		}
+		if err := enc.Encode(mk(nan)); err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			exp = buf.Bytes()
+			continue
+		}
+		if !bytes.Equal(exp, buf.Bytes()) {
+			t.Fatalf("run %d produced different output", i)
+		}
+	}
+
+	var v V
+	if err := Unmarshal(exp, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.SM[sK{5, -5}] != 5 || v.M["63"] != 63 || !math.IsNaN(v.IM[-1]) || len(v.FM) != 64 {
+		t.Fatalf("bad values: %+v", v)
+	}
+
+	for _, i := range []int64{math.MinInt8, math.MinInt16, math.MinInt32, math.MinInt64} {
+		var o int64
+		b, _ := Marshal(i)
+		if err := Unmarshal(b, &o); err != nil || o != i {
+			t.Fatalf("expected %d, got %d (%v)", i, o, err)
+		}
+	}
+}
+
+func TestInternFieldNames(t *testing.T) {
+	type v1 struct{ A, Z int }
+	type v2 struct {
+		A   int
+		New *S
+		Z   int
+	}
+	in := make([]v2, 100)
+	for i := range in {
+		in[i] = v2{i + 1, &S{Str: "x", U8: uint8(i) + 1}, -i - 1}
+	}
+
+	var buf bytes.Buffer
+	enc := NewEncoderOptions(&buf, EncoderOptions{InternFieldNames: true, Header: true})
+	for i := 0; i < 2; i++ { // the symbol table is shared by the whole stream
+		if err := enc.Encode(in); err != nil {
+			t.Fatal(err)
+		}
+	}
+	plain, _ := Marshal(in)
+	if buf.Len()/2 >= len(plain) {
+		t.Fatalf("interned size (%d) should be less than the normal size (%d)", buf.Len()/2, len(plain))
+	}
+
+	dec := NewDecoder(&buf)
+	var out []v2
+	if err := dec.Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("exp: %+v\ngot: %+v", in, out)
+	}
+	if h := dec.Header(); h == nil || h.Flags&FlagInternedNames == 0 {
+		t.Fatalf("unexpected header: %+v", h)
+	}
+	// the second copy only has symbol references, and New has to be skipped.
+	var old []v1
+	if err := dec.Decode(&old); err != nil {
+		t.Fatal(err)
+	}
+	if len(old) != 100 || old[99].A != 100 || old[99].Z != -100 {
+		t.Fatalf("unexpected value: %+v", old)
+	}
+}
+
+func TestPackStructs(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoderOptions(&buf, EncoderOptions{PackStructs: true})
+	if err := enc.Encode(&benchVal); err != nil {
+		t.Fatal(err)
+	}
+	plain, _ := Marshal(&benchVal)
+	if buf.Len() >= len(plain) {
+		t.Fatalf("packed size (%d) should be less than the normal size (%d)", buf.Len(), len(plain))
+	}
+
+	var s S
+	if err := Unmarshal(buf.Bytes(), &s); err != nil {
+		t.Fatal(err)
+	}
+	exp := benchVal
+	exp.Ignore = ""
+	if !reflect.DeepEqual(exp, s) {
+		t.Fatalf("exp: %+v\ngot: %+v", exp, s)
+	}
+
+	var v interface{}
+	if err := Unmarshal(buf.Bytes(), &v); err != nil {
+		t.Fatal(err)
+	}
+	if fields := v.([]interface{}); len(fields) != SLen || fields[0] != "hello" || fields[1] != int64(1) {
+		t.Fatalf("unexpected value: %#v", v)
+	}
+
+	if err := Unmarshal(buf.Bytes(), &struct{ Str string }{}); err == nil {
+		t.Fatal("expected an error decoding into a different struct")
+	}
+}
+
+func TestCompact(t *testing.T) {
+	for _, tc := range []struct {
+		v  interface{}
+		sz int
+	}{
+		{int64(70000), 4},
+		{uint64(70000), 4},
+		{int64(-5), 2},
+		{uint64(200), 2},
+		{int64(math.MinInt64), 9},
+		{uint64(1 << 60), 9},
+		{"hi", 4},
+	} {
+		enc := NewBytesEncoderOptions(nil, EncoderOptions{Compact: true})
+		if err := enc.Encode(tc.v); err != nil {
+			t.Fatal(err)
+		}
+		if len(enc.Bytes()) != tc.sz {
+			t.Fatalf("%T(%v): expected %d bytes, got %v", tc.v, tc.v, tc.sz, enc.Bytes())
+		}
+		out := reflect.New(reflect.TypeOf(tc.v))
+		if err := NewBytesDecoderOptions(enc.Bytes(), DecoderOptions{Compact: true}).Decode(out.Interface()); err != nil {
+			t.Fatal(err)
+		}
+		if out.Elem().Interface() != tc.v {
+			t.Fatalf("exp: %v, got: %v", tc.v, out.Elem())
+		}
+	}
+
+	enc := NewBytesEncoderOptions(nil, EncoderOptions{Compact: true, Header: true})
+	if err := enc.Encode(&benchVal); err != nil {
+		t.Fatal(err)
+	}
+	plain, _ := Marshal(&benchVal)
+	if sz := len(enc.Bytes()) - len(enc.header().appendTo(nil)); sz >= len(plain) {
+		t.Fatalf("compact size (%d) should be less than the normal size (%d)", sz, len(plain))
+	}
+	enc.BeginSlice()
+	enc.Encode([]int{1 << 20, -1 << 20})
+	enc.End()
+
+	dec := NewBytesDecoder(enc.Bytes())
+	var (
+		s S
+		v interface{}
+	)
+	if err := dec.Decode(&s); err != nil {
+		t.Fatal(err)
+	}
+	if h := dec.Header(); h == nil || h.Version != 2 || h.Flags != FlagCompact {
+		t.Fatalf("unexpected header: %+v", h)
+	}
+	exp := benchVal
+	exp.Ignore = ""
+	if !reflect.DeepEqual(exp, s) {
+		t.Fatalf("exp: %+v\ngot: %+v", exp, s)
+	}
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	if exp := []interface{}{[]interface{}{int64(1 << 20), int64(-1 << 20)}}; !reflect.DeepEqual(exp, v) {
+		t.Fatalf("exp: %v\ngot: %v", exp, v)
+	}
+
+	// tokens can convert a compact stream back to the normal format
+	dec = NewBytesDecoder(enc.Bytes())
+	out := NewBytesEncoder(nil)
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		if err = out.WriteToken(tok); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !bytes.HasPrefix(out.Bytes(), plain) {
+		t.Fatalf("exp: %v\ngot: %v", plain, out.Bytes())
+	}
+
+	b := Header{Version: 1, Flags: FlagCompact}.appendTo(nil)
+	var he *HeaderError
+	if err := Unmarshal(append(b, byte(Nil)), &v); !errors.As(err, &he) {
+		t.Fatalf("expected a HeaderError, got %v", err)
+	}
+}
+
+func TestEncoderCycles(t *testing.T) {
+	s := &S{Str: "loop"}
+	s.S = s
+	m := map[string]interface{}{}
+	m["m"] = m
+	sl := []interface{}{nil}
+	sl[0] = sl
+
+	for _, v := range []interface{}{s, m, sl} {
+		_, err := Marshal(v)
+		var ce *CycleError
+		if !errors.As(err, &ce) || !errors.Is(err, ErrCycle) {
+			t.Fatalf("%T: expected a CycleError, got %v", v, err)
+		}
+	}
+
+	// long but not cyclic
+	list := &S{}
+	for i, l := 0, list; i < 2*startDetectingCyclesAfter; i, l = i+1, l.S {
+		l.S = &S{I64: int64(i)}
+	}
+	b, err := Marshal(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the pooled encoder must be usable after an error
+	if _, err = Marshal(s); !errors.Is(err, ErrCycle) {
+		t.Fatalf("expected ErrCycle, got %v", err)
+	}
+	if b2, err := Marshal(list); err != nil || !bytes.Equal(b, b2) {
+		t.Fatalf("unexpected output: %v", err)
+	}
+
+	var buf bytes.Buffer
+	err = NewEncoderOptions(&buf, EncoderOptions{MaxDepth: 100}).Encode(list)
+	var le *LimitError
+	if !errors.As(err, &le) || le.Limit != "depth" || le.Max != 100 {
+		t.Fatalf("expected a depth LimitError, got %v", err)
+	}
+	if err = NewEncoderOptions(&buf, EncoderOptions{MaxDepth: 16}).Encode(&benchVal); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEncodeNilPointer(t *testing.T) {
+	type T struct {
+		P *S `binny:",keepzero"`
+		N int
+	}
+	for _, opts := range []EncoderOptions{{}, {References: true}, {KeepZeroFields: true, PackStructs: true}} {
+		enc := NewBytesEncoderOptions(nil, opts)
+		if err := enc.Encode((*S)(nil)); err != nil || !bytes.Equal(enc.Bytes(), []byte{byte(Nil)}) {
+			t.Fatalf("%+v: unexpected output: %v, %v", opts, enc.Bytes(), err)
+		}
+		enc = NewBytesEncoderOptions(nil, opts)
+		if err := enc.Encode(&T{N: 1}); err != nil {
+			t.Fatalf("%+v: %v", opts, err)
+		}
+		out := T{P: &S{}}
+		if err := Unmarshal(enc.Bytes(), &out); err != nil || out.P != nil || out.N != 1 {
+			t.Fatalf("%+v: unexpected value: %+v, %v", opts, out, err)
+		}
+	}
+}
+
 func BenchmarkEncodeMap(b *testing.B) {
 	m := map[string]int{}
 	for i := 0; i < 1000; i++ {
@@ -124,6 +481,24 @@ func benchEncoder(b *testing.B, o interface{}) {
 	b.SetBytes(ln)
 }
 
+func benchAppend(b *testing.B, o interface{}) {
+	buf := make([]byte, 0, 4096)
+	var ln int64
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		out, err := Append(buf, o)
+		if err != nil {
+			b.Fatal(err)
+		}
+		ln = int64(len(out))
+	}
+	b.SetBytes(ln)
+}
+
+func BenchmarkAppendBig(b *testing.B)   { benchAppend(b, &benchVal) }
+func BenchmarkAppendSmall(b *testing.B) { benchAppend(b, benchVal.S.S.S) }
+
 func BenchmarkMarshalerBig(b *testing.B) {
 	tmp := SI(benchVal)
 	benchEncoder(b, &tmp)
@@ -143,6 +518,24 @@ func BenchmarkEncoderBig(b *testing.B) {
 
 func BenchmarkEncoderSmall(b *testing.B) { benchEncoder(b, benchVal.S.S.S) }
 
+func benchEncoderPacked(b *testing.B, o interface{}) {
+	buf := bytes.NewBuffer(make([]byte, 0, 4096))
+	enc := NewEncoderOptions(buf, EncoderOptions{PackStructs: true})
+	var ln int64
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := enc.Encode(o); err != nil {
+			b.Fatal(err)
+		}
+		ln = int64(buf.Len())
+		buf.Reset()
+	}
+	b.SetBytes(ln)
+}
+
+func BenchmarkEncoderPackedBig(b *testing.B)   { benchEncoderPacked(b, &benchVal) }
+func BenchmarkEncoderPackedSmall(b *testing.B) { benchEncoderPacked(b, benchVal.S.S.S) }
+
 func BenchmarkEncoderNativeTypes(b *testing.B) {
 	v := &struct {
 		S1, S2, S3 string
@@ -0,0 +1,169 @@
+package binny
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// FormatVersion is the latest version of the format this package can read and write.
+// Version 2 added FlagCompact and FlagCompressed, streams that don't use them are still written as version 1.
+const FormatVersion = 2
+
+// headerMagic starts every stream that has a header, the first byte isn't a valid Type
+// so a decoder can always tell a header apart from a value.
+var headerMagic = [4]byte{0x89, 'B', 'N', 'Y'}
+
+// ErrNoHeader gets returned if the decoder requires a header and the stream doesn't start with one.
+var ErrNoHeader = errors.New("missing stream header")
+
+// HeaderFlags describe how a stream was encoded.
+type HeaderFlags uint64
+
+const (
+	FlagBigEndian     HeaderFlags = 1 << iota // fixed-width numbers are big-endian
+	FlagInternedNames                         // field names are interned, see EncoderOptions.InternFieldNames
+	FlagPackedStructs                         // structs are written by position, see EncoderOptions.PackStructs
+	FlagReferences                            // shared pointers are written once, see EncoderOptions.References
+	FlagCompact                               // integers and lengths are varints, see EncoderOptions.Compact
+	FlagCompressed                            // everything after the header is in compressed blocks, see EncoderOptions.Compression
+
+	knownFlags = FlagBigEndian | FlagInternedNames | FlagPackedStructs | FlagReferences | FlagCompact | FlagCompressed
+	v2Flags    = FlagCompact | FlagCompressed
+)
+
+// Header is the optional block written at the start of a stream by an Encoder with EncoderOptions.Header set.
+//
+//	header = [0x89 'B' 'N' 'Y'][version][varuint(flags)][codec if FlagCompressed is set]
+type Header struct {
+	Version uint8
+	Flags   HeaderFlags
+	Codec   uint8 // the ID of the Codec used with FlagCompressed
+}
+
+// HeaderError gets returned when a stream has a header the decoder can't handle.
+type HeaderError struct {
+	Header Header
+	Reason string
+}
+
+func (he *HeaderError) Error() string {
+	return fmt.Sprintf("invalid header (version %d, flags 0x%x): %s", he.Header.Version, uint64(he.Header.Flags), he.Reason)
+}
+
+func (h Header) byteOrder() binary.ByteOrder {
+	if h.Flags&FlagBigEndian != 0 {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+func (h Header) appendTo(b []byte) []byte {
+	b = append(b, headerMagic[:]...)
+	b = append(b, h.Version)
+	b = binary.AppendUvarint(b, uint64(h.Flags))
+	if h.Flags&FlagCompressed != 0 {
+		b = append(b, h.Codec)
+	}
+	return b
+}
+
+func (enc *Encoder) header() Header {
+	h := Header{Version: 1}
+	if enc.order == binary.BigEndian {
+		h.Flags |= FlagBigEndian
+	}
+	if enc.opts.InternFieldNames {
+		h.Flags |= FlagInternedNames
+	}
+	if enc.opts.PackStructs {
+		h.Flags |= FlagPackedStructs
+	}
+	if enc.opts.References {
+		h.Flags |= FlagReferences
+	}
+	if enc.opts.Compact {
+		h.Version, h.Flags = 2, h.Flags|FlagCompact
+	}
+	if enc.compressed() {
+		h.Version, h.Flags, h.Codec = 2, h.Flags|FlagCompressed, enc.opts.Compression
+	}
+	return h
+}
+
+func (enc *Encoder) writeHeader() error {
+	b := enc.header().appendTo(enc.buf[:0])
+	if enc.compressed() {
+		return enc.blocks.writeRaw(b)
+	}
+	_, err := enc.Write(b)
+	return err
+}
+
+// checkHeader reads the stream header if this is the first read since the decoder was created or reset.
+func (dec *Decoder) checkHeader() error {
+	if dec.hdrDone {
+		return dec.hdrErr
+	}
+	b, err := dec.peekByte()
+	if err != nil {
+		if err == io.EOF && dec.opts.RequireHeader {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	dec.hdrDone = true
+	if b != headerMagic[0] {
+		if dec.opts.RequireHeader {
+			dec.hdrErr = ErrNoHeader
+		}
+		return dec.hdrErr
+	}
+	dec.hdrErr = noEOF(dec.readHeader()) // it started with the magic, so it was cut short
+	return dec.hdrErr
+}
+
+func (dec *Decoder) readHeader() error {
+	var (
+		h     Header
+		magic [len(headerMagic) + 1]byte
+	)
+	if _, err := dec.readFull(magic[:]); err != nil {
+		return err
+	}
+	if [len(headerMagic)]byte(magic[:len(headerMagic)]) != headerMagic {
+		return &HeaderError{h, "bad magic"}
+	}
+	h.Version = magic[len(headerMagic)]
+	flags, err := dec.readUvarint()
+	if err != nil {
+		return err
+	}
+	h.Flags = HeaderFlags(flags)
+	if h.Flags&FlagCompressed != 0 {
+		if h.Codec, err = dec.readByte(); err != nil {
+			return err
+		}
+	}
+	codec, ok := registeredCodec(h.Codec)
+	switch {
+	case h.Version == 0 || h.Version > FormatVersion:
+		return &HeaderError{h, "unsupported version"}
+	case h.Flags&^knownFlags != 0, h.Flags&v2Flags != 0 && h.Version < 2:
+		return &HeaderError{h, "unsupported flags"}
+	case h.Flags&FlagCompressed != 0 && !ok:
+		return &HeaderError{h, "unknown codec"}
+	}
+	dec.hdr = &h
+	dec.order, dec.compact = h.byteOrder(), h.Flags&FlagCompact != 0
+	if h.Flags&FlagCompressed != 0 {
+		dec.startBlocks(codec)
+	}
+	return nil
+}
+
+// Header returns the stream header, or nil if the stream doesn't have one or nothing was read yet.
+func (dec *Decoder) Header() *Header {
+	return dec.hdr
+}
@@ -0,0 +1,52 @@
+package binny
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func TestHeader(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoderOptions(&buf, EncoderOptions{Header: true, ByteOrder: binary.BigEndian})
+	if err := enc.Encode(&benchVal); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode(uint16(0x0102)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), headerMagic[:]) {
+		t.Fatalf("missing header: %v", buf.Bytes()[:8])
+	}
+
+	// the decoder should pick up the byte order from the header.
+	dec := NewDecoderOptions(bytes.NewReader(buf.Bytes()), DecoderOptions{RequireHeader: true})
+	var (
+		s S
+		u uint16
+	)
+	if err := dec.Decode(&s); err != nil {
+		t.Fatal(err)
+	}
+	if err := dec.Decode(&u); err != nil {
+		t.Fatal(err)
+	}
+	if h := dec.Header(); h == nil || h.Version != 1 || h.Flags != FlagBigEndian {
+		t.Fatalf("unexpected header: %+v", h)
+	}
+	if u != 0x0102 || s.S.S.S.Str != benchVal.S.S.S.Str {
+		t.Fatalf("bad values: 0x%x %+v", u, s)
+	}
+
+	b, _ := Marshal(u)
+	if err := NewDecoderOptions(bytes.NewReader(b), DecoderOptions{RequireHeader: true}).Decode(&u); err != ErrNoHeader {
+		t.Fatalf("expected ErrNoHeader, got %v", err)
+	}
+
+	b = Header{Version: FormatVersion + 1}.appendTo(nil)
+	var he *HeaderError
+	if err := Unmarshal(append(b, b...), &u); !errors.As(err, &he) || he.Header.Version != FormatVersion+1 {
+		t.Fatalf("expected a HeaderError, got %v", err)
+	}
+}
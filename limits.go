@@ -0,0 +1,152 @@
+package binny
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"unsafe"
+)
+
+var (
+	// ErrLimitExceeded is wrapped by every *LimitError, use errors.Is(err, ErrLimitExceeded) to check for it.
+	ErrLimitExceeded = errors.New("limit exceeded")
+
+	// ErrCycle is wrapped by every *CycleError, use errors.Is(err, ErrCycle) to check for it.
+	ErrCycle = errors.New("cycle detected")
+)
+
+// LimitError gets returned when the input exceeds one of the limits set in DecoderOptions,
+// or the value being encoded exceeds EncoderOptions.MaxDepth.
+type LimitError struct {
+	Limit string // which limit, e.g. "slice length"
+	Max   int64
+	Value int64
+}
+
+func (le *LimitError) Error() string {
+	return fmt.Sprintf("%s exceeds the limit: %d > %d", le.Limit, le.Value, le.Max)
+}
+
+func (le *LimitError) Unwrap() error { return ErrLimitExceeded }
+
+func (dec *Decoder) checkLen(limit string, max int, ln uint64) error {
+	if max > 0 && ln > uint64(max) {
+		return &LimitError{limit, int64(max), int64(ln)}
+	}
+	return nil
+}
+
+func (dec *Decoder) checkTotal(n uint64) error {
+	if max := dec.opts.MaxTotalBytes; max > 0 && n > uint64(max-dec.off) {
+		total := uint64(dec.off) + n
+		if total < n || total > math.MaxInt64 {
+			total = math.MaxInt64
+		}
+		return &LimitError{"total bytes", max, int64(total)}
+	}
+	return nil
+}
+
+// maxPrealloc is how many bytes get allocated up front for a length read from an io.Reader,
+// anything bigger grows as the data comes in, so a bogus length can't allocate more than the input has.
+const maxPrealloc = 1 << 20
+
+// prealloc returns how many of ln entries of the given size to allocate up front,
+// every entry takes at least one byte of the input so it's never more than what is left of it.
+func (dec *Decoder) prealloc(ln int, size uintptr) int {
+	left := int64(maxPrealloc / (size + 1))
+	if dec.r == nil {
+		left = int64(len(dec.src) - dec.pos)
+	}
+	if max := dec.opts.MaxTotalBytes; max > 0 && max-dec.off < left {
+		left = max - dec.off
+	}
+	if int64(ln) > left {
+		return int(left)
+	}
+	return ln
+}
+
+// enter must be called before decoding the contents of a struct, map, slice or interface,
+// and followed by a leave once it's done.
+func (dec *Decoder) enter() error {
+	dec.depth++
+	if max := dec.opts.MaxDepth; max > 0 && dec.depth > max {
+		dec.depth--
+		return &LimitError{"depth", int64(max), int64(max + 1)}
+	}
+	return nil
+}
+
+func (dec *Decoder) leave() { dec.depth-- }
+
+// CycleError gets returned by the Encoder when a value refers back to itself through pointers, maps or slices.
+type CycleError struct {
+	Type reflect.Type
+}
+
+func (ce *CycleError) Error() string { return "encountered a cycle via " + ce.Type.String() }
+
+func (ce *CycleError) Unwrap() error { return ErrCycle }
+
+// startDetectingCyclesAfter is the nesting depth after which the Encoder starts tracking the pointers it visits,
+// like encoding/json it's only worth the cost once we're that deep.
+const startDetectingCyclesAfter = 1000
+
+// cycleKey identifies the data of a value, the length is needed for slices and the type
+// because a struct and its first field have the same address.
+type cycleKey struct {
+	p unsafe.Pointer
+	n int
+	t reflect.Type
+}
+
+// enter must be called before encoding the contents of a struct, map, slice or pointer,
+// and followed by a leave with the same value once it's done.
+func (enc *Encoder) enter(v reflect.Value) error {
+	enc.depth++
+	if max := enc.opts.MaxDepth; max > 0 && enc.depth > max {
+		enc.depth--
+		return &LimitError{"depth", int64(max), int64(max + 1)}
+	}
+	if enc.depth <= startDetectingCyclesAfter {
+		return nil
+	}
+	key, ok := newCycleKey(v)
+	if !ok {
+		return nil
+	}
+	if _, seen := enc.ptrSeen[key]; seen {
+		enc.depth--
+		return &CycleError{v.Type()}
+	}
+	if enc.ptrSeen == nil {
+		enc.ptrSeen = map[cycleKey]struct{}{}
+	}
+	enc.ptrSeen[key] = struct{}{}
+	return nil
+}
+
+func (enc *Encoder) leave(v reflect.Value) {
+	if enc.depth > startDetectingCyclesAfter {
+		if key, ok := newCycleKey(v); ok {
+			delete(enc.ptrSeen, key)
+		}
+	}
+	enc.depth--
+}
+
+func newCycleKey(v reflect.Value) (cycleKey, bool) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map:
+		return cycleKey{v.UnsafePointer(), 0, v.Type()}, true
+	case reflect.Slice:
+		return cycleKey{v.UnsafePointer(), v.Len(), v.Type()}, true
+	case reflect.Struct:
+		if v.CanAddr() {
+			return cycleKey{v.Addr().UnsafePointer(), 0, v.Type()}, true
+		}
+	}
+	return cycleKey{}, false
+}
@@ -0,0 +1,127 @@
+package binny
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"runtime"
+	"testing"
+)
+
+func TestDecoderLimits(t *testing.T) {
+	huge := Exp(Uint64, uint64(math.MaxUint64>>1)).b
+	nested, _ := Marshal([][][][][]int{{{{{1}}}}})
+	big, _ := Marshal(&benchVal)
+	opts := DecoderOptions{
+		MaxBytesLen:   1 << 10,
+		MaxSliceLen:   1 << 10,
+		MaxMapLen:     1 << 10,
+		MaxDepth:      4,
+		MaxTotalBytes: int64(len(big) - 1),
+	}
+
+	tests := []struct {
+		name  string
+		in    []byte
+		v     interface{}
+		limit string
+	}{
+		{"string", append([]byte{byte(String)}, huge...), new(string), "bytes length"},
+		{"[]byte", append([]byte{byte(ByteSlice)}, huge...), new(interface{}), "bytes length"},
+		{"slice", append([]byte{byte(Slice)}, huge...), new([]int), "slice length"},
+		{"generic slice", append([]byte{byte(Slice)}, huge...), new(interface{}), "slice length"},
+		{"map", append([]byte{byte(Map)}, huge...), new(map[string]int), "map length"},
+		{"depth", nested, new([][][][][]int), "depth"},
+		{"generic depth", nested, new(interface{}), "depth"},
+		{"total", big, new(S), "total bytes"},
+	}
+	for _, tt := range tests {
+		err := NewDecoderOptions(bytes.NewReader(tt.in), opts).Decode(tt.v)
+		var le *LimitError
+		if !errors.As(err, &le) || !errors.Is(err, ErrLimitExceeded) {
+			t.Fatalf("%s: expected a LimitError, got %v", tt.name, err)
+		}
+		if le.Limit != tt.limit {
+			t.Fatalf("%s: expected the %q limit, got %v", tt.name, tt.limit, err)
+		}
+	}
+
+	opts.MaxTotalBytes = int64(len(big))
+	var s S
+	if err := NewDecoderOptions(bytes.NewReader(big), opts).Decode(&s); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUntrustedLengths(t *testing.T) {
+	for _, ln := range []uint64{1 << 28, math.MaxUint64 >> 1} {
+		for _, typ := range []Type{Slice, Map, ByteSlice, String} {
+			in := append([]byte{byte(typ)}, Exp(Len(ln)).b...)
+			for _, v := range []interface{}{new(interface{}), new([]int), new([4]int), new(map[string]int), new([]byte), new(string)} {
+				if err := Unmarshal(in, v); err == nil {
+					t.Fatalf("%v(%d) into %T: expected an error", typ, ln, v)
+				}
+				if err := NewDecoder(bytes.NewReader(in)).Decode(v); err == nil {
+					t.Fatalf("%v(%d) into %T: expected an error", typ, ln, v)
+				}
+			}
+		}
+	}
+
+	// MaxTotalBytes fails before allocating anything for the length
+	opts := DecoderOptions{MaxTotalBytes: 1 << 20}
+	in := append([]byte{byte(ByteSlice)}, Exp(Len(1<<28)).b...)
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	before := ms.TotalAlloc
+	var b []byte
+	err := NewDecoderOptions(bytes.NewReader(in), opts).Decode(&b)
+	runtime.ReadMemStats(&ms)
+	if le := (*LimitError)(nil); !errors.As(err, &le) || le.Limit != "total bytes" {
+		t.Fatalf("expected a LimitError, got %v", err)
+	}
+	if n := ms.TotalAlloc - before; n > 1<<24 {
+		t.Fatalf("allocated %d bytes", n)
+	}
+
+	opts.MaxTotalBytes = 10
+	s := make([]int, 0, 100)
+	in = append([]byte{byte(Slice)}, Exp(Len(100)).b...)
+	if err = NewDecoderOptions(bytes.NewReader(in), opts).Decode(&s); !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expected a LimitError, got %v", err)
+	}
+
+	if opts := NewDecoder(nil).Options(); opts.MaxDepth != DefaultMaxDepth || opts.MaxSliceLen != DefaultMaxSliceLen {
+		t.Fatalf("unexpected default limits: %+v", opts)
+	}
+}
+
+func TestUntrustedPackedStruct(t *testing.T) {
+	// a bitmap that claims far more fields than the input has, or fields that aren't there
+	in := append([]byte{byte(PackedStruct)}, 0x80, 0x80, 0x80, 0x80, 0x04, 0xff, 0xff)
+	fields := append([]byte{byte(PackedStruct), 0x80, 0x80, 0x40}, bytes.Repeat([]byte{0xff}, 1<<17)...)
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	before := ms.TotalAlloc
+	for _, dec := range []*Decoder{
+		NewBytesDecoder(in), NewDecoder(bytes.NewReader(in)),
+		NewBytesDecoder(fields), NewDecoder(bytes.NewReader(fields)),
+	} {
+		var v interface{}
+		if err := dec.Decode(&v); err == nil {
+			t.Fatal("expected an error")
+		}
+	}
+	runtime.ReadMemStats(&ms)
+	if n := ms.TotalAlloc - before; n > 1<<23 {
+		t.Fatalf("allocated %d bytes", n)
+	}
+
+	// present fields that can't fit in what's left of MaxTotalBytes
+	in = append([]byte{byte(PackedStruct), 64}, bytes.Repeat([]byte{0xff}, 8)...)
+	opts := DecoderOptions{MaxTotalBytes: int64(len(in) + 10)}
+	var v interface{}
+	if err := NewBytesDecoderOptions(append(in, make([]byte, 64)...), opts).Decode(&v); !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expected a LimitError, got %v", err)
+	}
+}
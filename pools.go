@@ -1,8 +1,6 @@
 package binny
 
 import (
-	"bytes"
-	"io"
 	"sync"
 )
 
@@ -12,22 +10,21 @@ var pools = struct {
 }{
 	enc: sync.Pool{
 		New: func() interface{} {
-			buf := bytes.NewBuffer(make([]byte, 0, DefaultEncoderBufferSize))
-			eb := &encBuffer{b: buf, e: NewEncoder(buf)}
-			eb.e.NoAutoFlushOnEncode = true
+			eb := &encBuffer{e: NewBytesEncoder(make([]byte, 0, DefaultEncoderBufferSize))}
+			eb.opts = eb.e.opts
 			return eb
 		},
 	},
 	dec: sync.Pool{
 		New: func() interface{} {
-			return NewDecoder(nil)
+			return NewBytesDecoder(nil)
 		},
 	},
 }
 
 type encBuffer struct {
-	b *bytes.Buffer
-	e *Encoder
+	e    *Encoder
+	opts EncoderOptions
 }
 
 func getEncBuffer() *encBuffer {
@@ -35,18 +32,30 @@ func getEncBuffer() *encBuffer {
 	return eb
 }
 
+// getEncBufferFor returns a pooled encBuffer that encodes the same way as enc,
+// minus the header and the stream's symbol table.
+func getEncBufferFor(enc *Encoder) *encBuffer {
+	eb := getEncBuffer()
+	eb.e.order, eb.e.opts = enc.order, enc.opts
+	eb.e.opts.Header, eb.e.opts.InternFieldNames = false, false
+	return eb
+}
+
 func putEncBuffer(eb *encBuffer) {
-	eb.b.Reset()
+	eb.e.ResetBytes(eb.e.Bytes()[:0])
+	eb.e.order, eb.e.opts = eb.opts.ByteOrder, eb.opts
 	pools.enc.Put(eb)
 }
 
-func getDec(r io.Reader) *Decoder {
+func getDec(b []byte, noCopy bool) *Decoder {
 	dec := pools.dec.Get().(*Decoder)
-	dec.Reset(r)
+	dec.ResetBytes(b)
+	dec.opts.NoCopy = noCopy
 	return dec
 }
 
 func putDec(dec *Decoder) {
-	dec.Reset(nil)
+	dec.ResetBytes(nil)
+	dec.opts.NoCopy = false
 	pools.dec.Put(dec)
 }
@@ -0,0 +1,49 @@
+package binny
+
+// RawValue is a raw encoded entry, it's the binny version of json.RawMessage.
+// It can be used to delay decoding a value or to pass it through untouched.
+//
+// The bytes are written verbatim, so the stream it's written to must use the same byte order
+// as the one it was read from and be Compact only if that one was, otherwise the value gets misread.
+// It also shouldn't come from a stream with interned field names since it might refer to names
+// defined earlier in that stream.
+type RawValue []byte
+
+var (
+	_ Marshaler   = RawValue(nil)
+	_ Unmarshaler = (*RawValue)(nil)
+)
+
+// MarshalBinny writes the raw bytes, an empty RawValue is written as Nil.
+func (rv RawValue) MarshalBinny(enc *Encoder) error {
+	if len(rv) == 0 {
+		return enc.writeType(Nil)
+	}
+	_, err := enc.Write(rv)
+	return err
+}
+
+// UnmarshalBinny sets *rv to a copy of the next entry.
+func (rv *RawValue) UnmarshalBinny(dec *Decoder) (err error) {
+	*rv, err = dec.ReadRaw()
+	return
+}
+
+// ReadRaw returns the exact bytes of the next entry, including anything nested in it.
+func (dec *Decoder) ReadRaw() (RawValue, error) {
+	if _, err := dec.PeekType(); err != nil {
+		return nil, err
+	}
+	parent, wasCapturing := dec.raw, dec.capturing
+	dec.raw, dec.capturing = nil, true
+	err := dec.Skip()
+	raw := dec.raw
+	if wasCapturing { // a RawValue inside a RawValue
+		parent = append(parent, raw...)
+	}
+	dec.raw, dec.capturing = parent, wasCapturing
+	if err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
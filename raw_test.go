@@ -0,0 +1,53 @@
+package binny
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestRawValue(t *testing.T) {
+	type msg struct {
+		Kind    string
+		Payload *S
+		Extra   []interface{}
+	}
+	type envelope struct {
+		Kind    string
+		Payload RawValue
+		Extra   []RawValue
+	}
+
+	in := msg{"s", &benchVal, []interface{}{"x", nil, uint8(5)}}
+	b, err := Marshal(&in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var env envelope
+	if err = Unmarshal(b, &env); err != nil {
+		t.Fatal(err)
+	}
+	if len(env.Extra) != 3 || env.Extra[1] != nil || !bytes.Equal(env.Extra[2], []byte{byte(Uint8), 5}) {
+		t.Fatalf("unexpected Extra: %v", env.Extra)
+	}
+
+	// forwarding the envelope should produce the exact same bytes.
+	fwd, err := Marshal(&env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, fwd) {
+		t.Fatalf("exp: %v\ngot: %v", b, fwd)
+	}
+
+	var out S
+	if err = Unmarshal(env.Payload, &out); err != nil {
+		t.Fatal(err)
+	}
+	exp := benchVal
+	exp.Ignore = ""
+	if !reflect.DeepEqual(exp, out) {
+		t.Fatalf("exp: %+v\ngot: %+v", exp, out)
+	}
+}
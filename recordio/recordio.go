@@ -0,0 +1,262 @@
+// Package recordio frames binny values as records with a length prefix and a checksum,
+// so a file of appended records survives torn writes and corruption: the Reader reports a bad record
+// and skips to the next good one instead of failing in the middle of a value.
+//
+//	record = [0x8a 'R' 'E' 'C'][uint32(len(payload))][uint32(crc32c(len and payload))][payload]
+//
+// Numbers are little-endian and every payload is a standalone binny stream.
+package recordio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+
+	"github.com/missionMeteora/binny.v2"
+)
+
+const headerSize = 12
+
+// DefaultMaxRecordSize is the default max size of a record's payload the Reader accepts.
+const DefaultMaxRecordSize = 64 << 20
+
+var (
+	magic = [4]byte{0x8a, 'R', 'E', 'C'}
+
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// ErrChecksum is the Err of a CorruptError for a record that doesn't match its checksum.
+var ErrChecksum = errors.New("checksum mismatch")
+
+var errBadMagic = errors.New("bad magic")
+
+// CorruptError gets returned by the Reader for a record it can't read, the next call skips to the next good record.
+// A record cut short by the end of the input has an Err of io.ErrUnexpectedEOF.
+type CorruptError struct {
+	Offset int64 // where the bad record starts
+	Err    error
+}
+
+func (ce *CorruptError) Error() string {
+	return fmt.Sprintf("corrupt record at offset %d: %v", ce.Offset, ce.Err)
+}
+
+func (ce *CorruptError) Unwrap() error { return ce.Err }
+
+// Writer writes records to an io.Writer, every record is written with a single call to its Write method.
+type Writer struct {
+	w   io.Writer
+	enc *binny.Encoder
+	buf []byte
+}
+
+// NewWriter is an alias for NewWriterOptions(w, binny.EncoderOptions{})
+func NewWriter(w io.Writer) *Writer {
+	return NewWriterOptions(w, binny.EncoderOptions{})
+}
+
+// NewWriterOptions returns a new Writer that encodes records with the specified options,
+// every record gets its own encoder state, so interned names and references don't span records.
+func NewWriterOptions(w io.Writer, opts binny.EncoderOptions) *Writer {
+	return &Writer{
+		w:   w,
+		enc: binny.NewBytesEncoderOptions(nil, opts),
+		buf: make([]byte, headerSize, binny.DefaultEncoderBufferSize),
+	}
+}
+
+// Encode writes v as a record.
+func (w *Writer) Encode(v interface{}) error {
+	w.enc.ResetBytes(w.buf[:headerSize])
+	err := w.enc.Encode(v)
+	w.buf = w.enc.Bytes()
+	if err != nil {
+		return err
+	}
+	return w.write()
+}
+
+// WriteRecord writes p as the payload of a record as is.
+func (w *Writer) WriteRecord(p []byte) error {
+	w.buf = append(w.buf[:headerSize], p...)
+	return w.write()
+}
+
+// write fills in the header of the record in buf and writes it.
+func (w *Writer) write() error {
+	hdr, payload := w.buf[:headerSize], w.buf[headerSize:]
+	if uint64(len(payload)) > math.MaxUint32 {
+		return fmt.Errorf("record too big: %d bytes", len(payload))
+	}
+	copy(hdr, magic[:])
+	binary.LittleEndian.PutUint32(hdr[4:], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(hdr[8:], checksum(hdr, payload))
+	_, err := w.w.Write(w.buf)
+	return err
+}
+
+// checksum returns the CRC-32C of the length in hdr and of payload.
+func checksum(hdr, payload []byte) uint32 {
+	return crc32.Update(crc32.Checksum(hdr[4:8], crcTable), crcTable, payload)
+}
+
+// Reader reads the records written by a Writer.
+type Reader struct {
+	r    *bufio.Reader
+	dec  *binny.Decoder
+	off  int64  // the offset of the next byte, whether it's in pending or r
+	last int64  // the offset of the last record
+	bad  bool   // the last record was corrupt, so the next one has to be found
+	buf  []byte // the last record
+
+	// pending is read before r, it's what was left of a corrupt record after its first byte,
+	// since a good record might start in there.
+	pending []byte
+
+	// MaxRecordSize is the max size of a payload, a bigger record is considered corrupt.
+	// It defaults to DefaultMaxRecordSize.
+	MaxRecordSize int
+}
+
+// NewReader is an alias for NewReaderOptions(r, binny.DecoderOptions{})
+func NewReader(r io.Reader) *Reader {
+	return NewReaderOptions(r, binny.DecoderOptions{})
+}
+
+// NewReaderOptions returns a new Reader that decodes records with the specified options.
+// With NoCopy, decoded strings and byte slices are only valid until the next record is read.
+func NewReaderOptions(r io.Reader, opts binny.DecoderOptions) *Reader {
+	return &Reader{
+		r:             bufio.NewReader(r),
+		dec:           binny.NewBytesDecoderOptions(nil, opts),
+		MaxRecordSize: DefaultMaxRecordSize,
+	}
+}
+
+// Decode reads the next record and decodes it into v.
+// It returns io.EOF at the end of the input and a *CorruptError for a record that can't be read.
+func (r *Reader) Decode(v interface{}) error {
+	p, err := r.Next()
+	if err != nil {
+		return err
+	}
+	r.dec.ResetBytes(p)
+	return r.dec.Decode(v)
+}
+
+// Next reads the next record and returns its payload, which is only valid until the next call.
+// It returns io.EOF at the end of the input and a *CorruptError for a record that can't be read,
+// the next call then skips to the next good record. Errors from the underlying reader are returned as they are
+// and the next call tries to read the same record again.
+func (r *Reader) Next() ([]byte, error) {
+	if r.bad {
+		if err := r.resync(); err != nil {
+			return nil, err
+		}
+		r.bad = false
+	}
+	r.last = r.off
+	p, err := r.readRecord()
+	if err == io.EOF && len(r.buf) == 0 {
+		return nil, io.EOF
+	}
+	if err != nil && !isCorrupt(err) {
+		// an error from the underlying reader, the record can be read again if it was temporary
+		r.pending = append(append([]byte(nil), r.buf...), r.pending...)
+		r.off = r.last
+		return nil, err
+	}
+	if err != nil {
+		// a good record can start anywhere after the first byte of a bad one
+		r.bad = true
+		if len(r.buf) > 0 {
+			r.pending = append(append([]byte(nil), r.buf[1:]...), r.pending...)
+			r.off = r.last + 1
+		}
+		return nil, &CorruptError{r.last, err}
+	}
+	return p, nil
+}
+
+// isCorrupt reports whether err from readRecord is about the record rather than the underlying reader.
+func isCorrupt(err error) bool {
+	var le *binny.LimitError
+	return err == errBadMagic || err == ErrChecksum || err == io.ErrUnexpectedEOF || errors.As(err, &le)
+}
+
+// Offset returns the offset in the input of the last record returned by Next or Decode.
+func (r *Reader) Offset() int64 {
+	return r.last
+}
+
+// readRecord reads a record into r.buf, on errors r.buf has everything that was read.
+func (r *Reader) readRecord() ([]byte, error) {
+	r.buf = r.buf[:0]
+	if err := r.read(headerSize); err != nil {
+		if err == io.EOF && len(r.buf) > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	hdr := r.buf[:headerSize]
+	if [4]byte(hdr[:4]) != magic {
+		return nil, errBadMagic
+	}
+	ln := binary.LittleEndian.Uint32(hdr[4:])
+	if int64(ln) > int64(r.MaxRecordSize) {
+		return nil, &binny.LimitError{Limit: "record size", Max: int64(r.MaxRecordSize), Value: int64(ln)}
+	}
+	if err := r.read(int(ln)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	hdr, p := r.buf[:headerSize], r.buf[headerSize:]
+	if checksum(hdr, p) != binary.LittleEndian.Uint32(hdr[8:]) {
+		return nil, ErrChecksum
+	}
+	return p, nil
+}
+
+// read appends the next n bytes to r.buf, or as many as there are before an error.
+func (r *Reader) read(n int) error {
+	start := len(r.buf)
+	if free := cap(r.buf) - start; free < n {
+		r.buf = append(r.buf[:cap(r.buf)], make([]byte, n-free)...)
+	}
+	b := r.buf[start : start+n]
+	c := copy(b, r.pending)
+	r.pending = r.pending[c:]
+	m, err := io.ReadFull(r.r, b[c:])
+	r.buf = r.buf[:start+c+m]
+	r.off += int64(c + m)
+	return err
+}
+
+// resync skips to the next magic, leaving it in pending.
+func (r *Reader) resync() error {
+	for {
+		if n := len(r.pending); n < len(magic) {
+			var b [len(magic)]byte
+			m, err := io.ReadFull(r.r, b[:len(magic)-n])
+			r.pending = append(r.pending, b[:m]...)
+			if err == io.ErrUnexpectedEOF {
+				err = io.EOF // there's no room left for a record
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if [4]byte(r.pending[:len(magic)]) == magic {
+			return nil
+		}
+		r.pending = r.pending[1:]
+		r.off++
+	}
+}
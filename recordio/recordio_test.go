@@ -0,0 +1,191 @@
+package recordio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/missionMeteora/binny.v2"
+)
+
+type record struct {
+	ID   int
+	Name string
+	Tags []string
+}
+
+func writeRecords(t *testing.T, n int) ([]byte, []int64) {
+	var (
+		buf  bytes.Buffer
+		offs []int64
+	)
+	w := NewWriterOptions(&buf, binny.EncoderOptions{InternFieldNames: true})
+	for i := 0; i < n; i++ {
+		offs = append(offs, int64(buf.Len()))
+		if err := w.Encode(&record{ID: i, Name: "record", Tags: []string{"a", "b"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes(), offs
+}
+
+// readAll returns the IDs of the good records and the offsets of the corrupt ones.
+func readAll(t *testing.T, b []byte) (ids []int, bad []int64) {
+	r := NewReader(bytes.NewReader(b))
+	for {
+		var rec record
+		err := r.Decode(&rec)
+		if err == io.EOF {
+			return
+		}
+		var ce *CorruptError
+		if errors.As(err, &ce) {
+			bad = append(bad, ce.Offset)
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Offset() < 0 || rec.Name != "record" || !reflect.DeepEqual(rec.Tags, []string{"a", "b"}) {
+			t.Fatalf("unexpected record at %d: %+v", r.Offset(), rec)
+		}
+		ids = append(ids, rec.ID)
+	}
+}
+
+func TestRecords(t *testing.T) {
+	b, offs := writeRecords(t, 5)
+	if ids, bad := readAll(t, b); !reflect.DeepEqual(ids, []int{0, 1, 2, 3, 4}) || bad != nil {
+		t.Fatalf("unexpected result: %v %v", ids, bad)
+	}
+
+	// a torn write at the end
+	for _, n := range []int{1, 5, headerSize, headerSize + 3} {
+		ids, bad := readAll(t, b[:int(offs[4])+n])
+		if !reflect.DeepEqual(ids, []int{0, 1, 2, 3}) || !reflect.DeepEqual(bad, []int64{offs[4]}) {
+			t.Fatalf("%d: unexpected result: %v %v", n, ids, bad)
+		}
+	}
+	r := NewReader(bytes.NewReader(b[:offs[1]+5]))
+	r.Next()
+	if _, err := r.Next(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+
+	// a flipped bit in the payload
+	c := append([]byte(nil), b...)
+	c[offs[2]+headerSize+3] ^= 1
+	if ids, bad := readAll(t, c); !reflect.DeepEqual(ids, []int{0, 1, 3, 4}) || !reflect.DeepEqual(bad, []int64{offs[2]}) {
+		t.Fatalf("unexpected result: %v %v", ids, bad)
+	}
+	r = NewReader(bytes.NewReader(c))
+	r.Next()
+	r.Next()
+	if _, err := r.Next(); !errors.Is(err, ErrChecksum) {
+		t.Fatalf("expected ErrChecksum, got %v", err)
+	}
+
+	// a broken length and garbage between records
+	c = append([]byte(nil), b[:offs[3]]...)
+	c[offs[1]+5] = 0xff
+	c = append(c, 0x8a, 'R', 'E', 1, 2, 3)
+	c = append(c, b[offs[3]:]...)
+	if ids, bad := readAll(t, c); !reflect.DeepEqual(ids, []int{0, 2, 3, 4}) || !reflect.DeepEqual(bad, []int64{offs[1], offs[3]}) {
+		t.Fatalf("unexpected result: %v %v", ids, bad)
+	}
+}
+
+func TestWriteRecord(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	payloads := [][]byte{[]byte("raw"), nil, bytes.Repeat([]byte{0x8a, 'R', 'E', 'C'}, 100)}
+	for _, p := range payloads {
+		if err := w.WriteRecord(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := NewReader(&buf)
+	for _, exp := range payloads {
+		p, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(exp, p) {
+			t.Fatalf("exp: %q\ngot: %q", exp, p)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+
+	buf.Reset()
+	w.WriteRecord(make([]byte, 100))
+	r = NewReader(&buf)
+	r.MaxRecordSize = 10
+	var le *binny.LimitError
+	if _, err := r.Next(); !errors.As(err, &le) {
+		t.Fatalf("expected a LimitError, got %v", err)
+	}
+}
+
+// failingReader fails once after reading failAt bytes, or every time if failAt is negative.
+type failingReader struct {
+	r      io.Reader
+	failAt int
+	n      int
+	failed bool
+}
+
+var errDisk = errors.New("disk gone")
+
+func (fr *failingReader) Read(p []byte) (int, error) {
+	if fr.failAt < 0 || fr.n == fr.failAt && !fr.failed {
+		fr.failed = true
+		return 0, errDisk
+	}
+	if fr.n < fr.failAt && len(p) > fr.failAt-fr.n {
+		p = p[:fr.failAt-fr.n]
+	}
+	n, err := fr.r.Read(p)
+	fr.n += n
+	return n, err
+}
+
+func TestReadError(t *testing.T) {
+	r := NewReader(&failingReader{failAt: -1})
+	for i := 0; i < 2; i++ {
+		var ce *CorruptError
+		if _, err := r.Next(); err != errDisk || errors.As(err, &ce) {
+			t.Fatalf("expected errDisk, got %v", err)
+		}
+	}
+
+	// the record that failed can be read once the reader recovers
+	b, offs := writeRecords(t, 3)
+	fr := &failingReader{r: bytes.NewReader(b), failAt: int(offs[1]) + headerSize + 2}
+	r = NewReader(fr)
+	var ids []int
+	for {
+		var rec record
+		err := r.Decode(&rec)
+		if err == io.EOF {
+			break
+		}
+		if err == errDisk {
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Offset() != offs[rec.ID] {
+			t.Fatalf("expected record %d at %d, got %d", rec.ID, offs[rec.ID], r.Offset())
+		}
+		ids = append(ids, rec.ID)
+	}
+	if !fr.failed || !reflect.DeepEqual(ids, []int{0, 1, 2}) {
+		t.Fatalf("unexpected records: %v", ids)
+	}
+}
@@ -0,0 +1,111 @@
+package binny
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// refEncoder wraps the encoder of a pointer type, with EncoderOptions.References the first time a pointer
+// is seen its value gets written as a Ref, every other time only the index of that Ref is written.
+//
+//	[Ref][entry(value)] ... [BackRef][varuint(index)]
+func refEncoder(fn encoderFunc) encoderFunc {
+	return func(e *Encoder, v reflect.Value) error {
+		if !e.opts.References || v.IsNil() {
+			return fn(e, v)
+		}
+		key, _ := newCycleKey(v)
+		if id, ok := e.refs[key]; ok {
+			e.writeType(BackRef)
+			return e.writeVarUint(id)
+		}
+		if e.refs == nil {
+			e.refs = map[cycleKey]uint64{}
+		}
+		// the index is taken before writing the value so it can refer to itself
+		e.refs[key] = uint64(len(e.refs))
+		e.writeType(Ref)
+		return fn(e, v)
+	}
+}
+
+// refDecoder wraps the decoder of a pointer type, a Ref allocates the value and remembers the pointer
+// before decoding into it, a BackRef sets v to a pointer remembered earlier.
+func refDecoder(fn decoderFunc) decoderFunc {
+	return func(d *Decoder, v reflect.Value) error {
+		switch d.peekType() {
+		case Ref:
+			d.readType()
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			d.refs = append(d.refs, v.Elem().Addr())
+		case BackRef:
+			return d.setBackRef(v)
+		}
+		return fn(d, v)
+	}
+}
+
+// setBackRef reads a BackRef entry and sets v to the pointer it refers to.
+func (dec *Decoder) setBackRef(v reflect.Value) error {
+	if err := dec.expectType(BackRef); err != nil {
+		return err
+	}
+	p, err := dec.readBackRef()
+	if err != nil {
+		return err
+	}
+	if !p.Type().AssignableTo(v.Type()) {
+		return fmt.Errorf("reference to a %v can't be assigned to %v", p.Type(), v.Type())
+	}
+	if !v.CanSet() {
+		return fmt.Errorf("can't set a reference into %v", v.Type())
+	}
+	v.Set(p)
+	return nil
+}
+
+// readBackRef reads the index of a BackRef and returns the pointer it refers to.
+func (dec *Decoder) readBackRef() (reflect.Value, error) {
+	id, err := dec.readUvarint()
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if id >= uint64(len(dec.refs)) {
+		return reflect.Value{}, fmt.Errorf("invalid reference %d, only %d values were read", id, len(dec.refs))
+	}
+	p := dec.refs[id]
+	if !p.IsValid() {
+		// skipped values aren't kept, and readValue only knows its value once it's done decoding it
+		return p, fmt.Errorf("reference %d points to a skipped value or to one that's still being decoded", id)
+	}
+	return p, nil
+}
+
+// readRefValue is readValue for Ref and BackRef entries.
+func (dec *Decoder) readRefValue() (interface{}, error) {
+	ft, err := dec.readType()
+	if err != nil {
+		return nil, err
+	}
+	if ft == BackRef {
+		p, err := dec.readBackRef()
+		if err != nil {
+			return nil, err
+		}
+		return p.Interface(), nil
+	}
+	// a Ref can be followed by another one, so it counts as a level of nesting
+	if err = dec.enter(); err != nil {
+		return nil, err
+	}
+	defer dec.leave()
+	id := len(dec.refs)
+	dec.refs = append(dec.refs, reflect.Value{})
+	v, err := dec.readValue()
+	if err == nil && v != nil {
+		dec.refs[id] = reflect.ValueOf(v)
+	}
+	return v, err
+}
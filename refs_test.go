@@ -0,0 +1,141 @@
+package binny
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type refNode struct {
+	Name     string
+	Next     *refNode
+	Children []*refNode
+	Value    *int
+	Any      interface{}
+}
+
+func encodeRefs(t *testing.T, v interface{}) []byte {
+	var buf bytes.Buffer
+	if err := NewEncoderOptions(&buf, EncoderOptions{References: true}).Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReferences(t *testing.T) {
+	n := 42
+	shared := &refNode{Name: "shared", Value: &n}
+	root := &refNode{Name: "root", Value: &n, Children: []*refNode{shared, shared}, Any: shared}
+	root.Next = root
+
+	b := encodeRefs(t, root)
+	if plain, err := Marshal(&refNode{Children: []*refNode{shared, shared}}); err != nil {
+		t.Fatal(err)
+	} else if c := bytes.Count(b, []byte("shared")); c != 1 || bytes.Count(plain, []byte("shared")) != 2 {
+		t.Fatalf("expected the shared node to be written once, got %d", c)
+	}
+
+	var out refNode
+	if err := Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Next != &out {
+		t.Fatal("the cycle wasn't rebuilt")
+	}
+	if out.Children[0] != out.Children[1] || out.Children[0].Name != "shared" {
+		t.Fatalf("the children aren't shared: %+v", out.Children)
+	}
+	if out.Value != out.Children[0].Value || *out.Value != 42 {
+		t.Fatal("the *int field isn't shared")
+	}
+	if out.Any != out.Children[0] {
+		t.Fatalf("the interface doesn't point to the shared node: %#v", out.Any)
+	}
+
+	// references are scoped to a single Encode call
+	var buf bytes.Buffer
+	enc := NewEncoderOptions(&buf, EncoderOptions{References: true})
+	for i := 0; i < 2; i++ {
+		if err := enc.Encode(shared); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dec := NewDecoder(&buf)
+	for i := 0; i < 2; i++ {
+		var out *refNode
+		if err := dec.Decode(&out); err != nil {
+			t.Fatal(err)
+		}
+		if out.Name != "shared" || *out.Value != 42 {
+			t.Fatalf("unexpected value: %+v", out)
+		}
+	}
+}
+
+func TestReferencesGeneric(t *testing.T) {
+	shared := &refNode{Name: "shared"}
+	b := encodeRefs(t, []*refNode{shared, shared})
+
+	var out interface{}
+	if err := Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	s := out.([]interface{})
+	if len(s) != 2 || !reflect.DeepEqual(s[0], s[1]) || s[0].(map[string]interface{})["Name"] != "shared" {
+		t.Fatalf("unexpected value: %#v", out)
+	}
+
+	root := &refNode{Name: "root"}
+	root.Next = root
+	if err := Unmarshal(encodeRefs(t, root), &out); err == nil {
+		t.Fatal("expected an error decoding a cycle into an interface{}")
+	}
+}
+
+func TestReferencesSkip(t *testing.T) {
+	type small struct {
+		Children []*refNode
+	}
+	shared := &refNode{Name: "shared"}
+	b := encodeRefs(t, &refNode{Next: shared, Children: []*refNode{shared}})
+
+	var out small
+	if err := Unmarshal(b, &out); err == nil {
+		t.Fatal("expected an error referencing a skipped value")
+	}
+
+	b = encodeRefs(t, &refNode{Children: []*refNode{shared}, Any: shared})
+	if err := Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Children) != 1 || out.Children[0].Name != "shared" {
+		t.Fatalf("unexpected value: %+v", out)
+	}
+
+	for _, in := range [][]byte{
+		{byte(BackRef), 0},
+		{byte(Slice), byte(Uint8), 2, byte(Ref), byte(EmptyStruct), byte(BackRef), 1, byte(EOV)},
+	} {
+		var out []*struct{}
+		if err := Unmarshal(in, &out); err == nil {
+			t.Fatalf("%v: expected an invalid reference error", in)
+		}
+	}
+}
+
+func TestReferencesDepth(t *testing.T) {
+	in := append(bytes.Repeat([]byte{byte(Ref)}, DefaultMaxDepth+1), byte(Nil))
+	var le *LimitError
+	var v interface{}
+	if err := Unmarshal(in, &v); !errors.As(err, &le) || le.Limit != "depth" {
+		t.Fatalf("expected a LimitError, got %v", err)
+	}
+	if err := NewBytesDecoder(in).Skip(); !errors.As(err, &le) || le.Limit != "depth" {
+		t.Fatalf("expected a LimitError, got %v", err)
+	}
+	in = in[DefaultMaxDepth-10:]
+	if err := Unmarshal(in, &v); err != nil || v != nil {
+		t.Fatalf("unexpected value: %v, %v", v, err)
+	}
+}
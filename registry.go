@@ -0,0 +1,78 @@
+package binny
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+var registry = struct {
+	sync.RWMutex
+	names map[reflect.Type]string
+	types map[string]reflect.Type
+}{
+	names: map[reflect.Type]string{},
+	types: map[string]reflect.Type{},
+}
+
+// Register records a type under the given name, like gob.Register.
+// Values of registered types stored in interfaces get written as an Interface entry that carries the name,
+// which allows decoding them back into interface fields, e.g. a field of type io.Reader.
+//
+// Both ends of the stream have to register the same types under the same names,
+// registering a type or a name twice with different values panics.
+func Register(name string, value interface{}) {
+	if name == "" {
+		panic("binny: attempt to register an empty name")
+	}
+	t := reflect.TypeOf(value)
+	if t == nil {
+		panic("binny: attempt to register a nil value")
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+	if ot, ok := registry.types[name]; ok && ot != t {
+		panic(fmt.Sprintf("binny: registering duplicate types for %q: %v != %v", name, ot, t))
+	}
+	if on, ok := registry.names[t]; ok && on != name {
+		panic(fmt.Sprintf("binny: registering duplicate names for %v: %q != %q", t, on, name))
+	}
+	registry.names[t] = name
+	registry.types[name] = t
+}
+
+func registeredName(t reflect.Type) (name string, ok bool) {
+	registry.RLock()
+	name, ok = registry.names[t]
+	registry.RUnlock()
+	return
+}
+
+func registeredType(name string) (t reflect.Type, ok bool) {
+	registry.RLock()
+	t, ok = registry.types[name]
+	registry.RUnlock()
+	return
+}
+
+// readIface reads an Interface entry and returns the decoded value of its registered type.
+func (dec *Decoder) readIface() (reflect.Value, error) {
+	if err := dec.expectType(Interface); err != nil {
+		return reflect.Value{}, err
+	}
+	if err := dec.enter(); err != nil {
+		return reflect.Value{}, err
+	}
+	defer dec.leave()
+	name, err := dec.readName()
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	t, ok := registeredType(name)
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("binny: name not registered for interface: %q", name)
+	}
+	v := reflect.New(t).Elem()
+	return v, typeDecoder(t)(dec, v)
+}
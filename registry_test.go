@@ -0,0 +1,65 @@
+package binny
+
+import (
+	"reflect"
+	"testing"
+)
+
+type shape interface {
+	Area() float64
+}
+
+type circle struct{ R float64 }
+
+func (c circle) Area() float64 { return 3 * c.R * c.R }
+
+type rect struct{ W, H float64 }
+
+func (r *rect) Area() float64 { return r.W * r.H }
+
+func init() {
+	Register("circle", circle{})
+	Register("rect", &rect{})
+}
+
+func TestRegister(t *testing.T) {
+	type event struct {
+		Main    shape
+		Shapes  []shape
+		Payload interface{}
+		Nothing shape
+	}
+	in := event{
+		Main:    &rect{2, 3},
+		Shapes:  []shape{circle{1}, nil, &rect{4, 5}},
+		Payload: circle{2},
+	}
+	b, err := Marshal(&in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out event
+	if err = Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("exp: %#v\ngot: %#v", in, out)
+	}
+
+	var v interface{}
+	if err = Unmarshal(b, &v); err != nil {
+		t.Fatal(err)
+	}
+	if p := v.(map[string]interface{})["Payload"]; p != (circle{2}) {
+		t.Fatalf("unexpected payload: %#v", p)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected a panic on a duplicate name")
+			}
+		}()
+		Register("circle", rect{})
+	}()
+}
@@ -0,0 +1,180 @@
+package binny
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Schema describes how binny encodes a Go type, see SchemaOf. It can itself be encoded with binny.
+type Schema struct {
+	// Types has the type SchemaOf was called with first, followed by every type it refers to.
+	Types []TypeSchema
+}
+
+// TypeSchema describes one of the types of a Schema, types refer to each other by their index in Schema.Types.
+// Pointers are written as the value they point to, so they're described by their element type.
+type TypeSchema struct {
+	Name string // as returned by reflect.Type.String, e.g. "time.Time" or "[]string"
+	Kind string // the Go kind, e.g. "struct" or "slice"
+
+	// Type is the entry type values are written with. Integers can also be written with any smaller size
+	// or as a varint, bools are BoolTrue or BoolFalse, structs are PackedStruct with EncoderOptions.PackStructs
+	// and interfaces are written as their concrete value unless it's a registered type.
+	// It's Nil for a Marshaler, which can write anything.
+	Type Type
+
+	// Custom is "Marshaler", "BinaryMarshaler" or "GobEncoder" if the type encodes itself.
+	// A Marshaler is opaque, it has no Fields, Key or Elem whatever its Go type looks like.
+	Custom string
+
+	Registered string // the name the type was registered with, see Register
+
+	Fields []FieldSchema // a struct's fields in the order they're written, which is their position in a packed struct
+	Key    int           // the index of a map's key type, -1 for other kinds
+	Elem   int           // the index of the element type of a map, slice or array, -1 for other kinds and []byte
+	Len    int           // the length of an array
+}
+
+// FieldSchema describes a struct field.
+type FieldSchema struct {
+	Name  string // the name it's written with
+	Index []int  // its index in the Go struct, see reflect.Value.FieldByIndex
+	Type  int    // the index of its type in Schema.Types
+
+	OmitEmpty bool // tagged omitempty
+	KeepZero  bool // tagged keepzero
+	String    bool // tagged string, so it's written as a String
+}
+
+// SchemaOf returns the schema of the type of v, which can be a nil pointer, e.g. SchemaOf((*T)(nil)).
+// It returns an error wrapping ErrUnsupportedType if the type or any type it refers to can't be encoded.
+func SchemaOf(v interface{}) (*Schema, error) {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return nil, fmt.Errorf("nil: %w", ErrUnsupportedType)
+	}
+	sb := schemaBuilder{index: map[reflect.Type]int{}}
+	if _, err := sb.add(t); err != nil {
+		return nil, err
+	}
+	return &sb.s, nil
+}
+
+type schemaBuilder struct {
+	s     Schema
+	index map[reflect.Type]int
+}
+
+// add adds t and the types it refers to, then returns its index.
+func (sb *schemaBuilder) add(t reflect.Type) (int, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if i, ok := sb.index[t]; ok {
+		return i, nil
+	}
+	i := len(sb.s.Types)
+	sb.index[t] = i // before adding anything else, so recursive types refer to themselves
+	sb.s.Types = append(sb.s.Types, TypeSchema{})
+
+	ts := TypeSchema{
+		Name:   t.String(),
+		Kind:   t.Kind().String(),
+		Custom: customEncoding(t),
+		Key:    -1,
+		Elem:   -1,
+	}
+	if name, ok := registeredName(t); ok {
+		ts.Registered = name
+	} else if name, ok = registeredName(reflect.PtrTo(t)); ok {
+		ts.Registered = name
+	}
+
+	var err error
+	switch k := t.Kind(); {
+	case ts.Custom == "Marshaler":
+		// what it writes is up to its MarshalBinny method
+	case ts.Custom == "BinaryMarshaler":
+		ts.Type = Binary
+	case ts.Custom == "GobEncoder":
+		ts.Type = Gob
+	case k == reflect.Bool:
+		ts.Type = BoolTrue
+	case k >= reflect.Int && k <= reflect.Int64:
+		ts.Type = Int64
+	case k >= reflect.Uint && k <= reflect.Uintptr:
+		ts.Type = Uint64
+	case k == reflect.Float32:
+		ts.Type = Float32
+	case k == reflect.Float64:
+		ts.Type = Float64
+	case k == reflect.Complex64:
+		ts.Type = Complex64
+	case k == reflect.Complex128:
+		ts.Type = Complex128
+	case k == reflect.String:
+		ts.Type = String
+	case k == reflect.Interface:
+		ts.Type = Interface
+	case k == reflect.Map && isNative(t.Key().Kind(), true):
+		ts.Type = Map
+		if ts.Key, err = sb.add(t.Key()); err == nil {
+			ts.Elem, err = sb.add(t.Elem())
+		}
+	case k == reflect.Slice || k == reflect.Array:
+		if k == reflect.Array {
+			ts.Len = t.Len()
+		}
+		if t.Elem().Kind() == reflect.Uint8 {
+			ts.Type = ByteSlice
+		} else {
+			ts.Type = Slice
+			ts.Elem, err = sb.add(t.Elem())
+		}
+	case k == reflect.Struct:
+		ts.Type, ts.Fields, err = sb.addFields(t)
+	default:
+		err = fmt.Errorf("%v: %w", t, ErrUnsupportedType)
+	}
+	sb.s.Types[i] = ts
+	return i, err
+}
+
+func (sb *schemaBuilder) addFields(t reflect.Type) (Type, []FieldSchema, error) {
+	fields := cachedTypeFields(t)
+	if len(fields) == 0 {
+		return EmptyStruct, nil, nil
+	}
+	out := make([]FieldSchema, 0, len(fields))
+	for i := range fields {
+		f := &fields[i]
+		ft, err := sb.add(f.typ)
+		if err != nil {
+			return Struct, nil, err
+		}
+		out = append(out, FieldSchema{
+			Name:      f.name,
+			Index:     append([]int(nil), f.index...),
+			Type:      ft,
+			OmitEmpty: f.omitEmpty,
+			KeepZero:  f.keepZero,
+			String:    f.quoted,
+		})
+	}
+	return Struct, out, nil
+}
+
+// customEncoding returns which interface t encodes itself with, in the same order newTypeEncoder checks them.
+func customEncoding(t reflect.Type) string {
+	for _, t := range []reflect.Type{t, reflect.PtrTo(t)} {
+		switch {
+		case t.Implements(marshalerType):
+			return "Marshaler"
+		case t.Implements(binaryMarshalerType):
+			return "BinaryMarshaler"
+		case t.Implements(gobEncoderType):
+			return "GobEncoder"
+		}
+	}
+	return ""
+}
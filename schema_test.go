@@ -0,0 +1,107 @@
+package binny
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// chanMarshaler can't be encoded by reflection, but it encodes itself.
+type chanMarshaler chan int
+
+func (chanMarshaler) MarshalBinny(enc *Encoder) error { return enc.WriteInt(1) }
+
+func TestSchemaOf(t *testing.T) {
+	type doc struct {
+		ID      int               `binny:"id"`
+		Count   uint16            `binny:",string"`
+		Note    string            `binny:"note,omitempty"`
+		Flags   map[string][]int8 `binny:",keepzero"`
+		Hash    [4]byte
+		When    time.Time
+		Shape   shape
+		Circle  *circle
+		Custom  SI
+		Self    *S
+		Raw     RawValue
+		Chan    chanMarshaler
+		ignored int
+		Skip    bool `binny:"-"`
+	}
+	s, err := SchemaOf((*doc)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := map[string]TypeSchema{}
+	for _, ts := range s.Types {
+		byName[ts.Name] = ts
+	}
+	root := s.Types[0]
+	if root.Name != "binny.doc" || root.Kind != "struct" || root.Type != Struct || len(root.Fields) != 12 {
+		t.Fatalf("unexpected root: %+v", root)
+	}
+	var names []string
+	for _, f := range root.Fields {
+		names = append(names, f.Name)
+	}
+	if exp := []string{"id", "Count", "note", "Flags", "Hash", "When", "Shape", "Circle", "Custom", "Self", "Raw", "Chan"}; !reflect.DeepEqual(exp, names) {
+		t.Fatalf("exp: %v\ngot: %v", exp, names)
+	}
+	if f := root.Fields[1]; !f.String || s.Types[f.Type].Type != Uint64 || !reflect.DeepEqual(f.Index, []int{1}) {
+		t.Fatalf("unexpected field: %+v", f)
+	}
+	if !root.Fields[2].OmitEmpty || !root.Fields[3].KeepZero {
+		t.Fatalf("unexpected tag options: %+v", root.Fields)
+	}
+
+	m := s.Types[root.Fields[3].Type]
+	if m.Type != Map || s.Types[m.Key].Type != String || s.Types[m.Elem].Type != Slice || s.Types[s.Types[m.Elem].Elem].Name != "int8" {
+		t.Fatalf("unexpected map: %+v", m)
+	}
+	if h := s.Types[root.Fields[4].Type]; h.Type != ByteSlice || h.Len != 4 || h.Elem != -1 {
+		t.Fatalf("unexpected array: %+v", h)
+	}
+	if w := byName["time.Time"]; w.Type != Binary || w.Custom != "BinaryMarshaler" || w.Fields != nil {
+		t.Fatalf("unexpected time: %+v", w)
+	}
+	if sh := byName["binny.shape"]; sh.Type != Interface {
+		t.Fatalf("unexpected interface: %+v", sh)
+	}
+	if c := byName["binny.circle"]; c.Registered != "circle" || c.Type != Struct {
+		t.Fatalf("unexpected circle: %+v", c)
+	}
+	for _, name := range []string{"binny.SI", "binny.RawValue", "binny.chanMarshaler"} {
+		if m := byName[name]; m.Custom != "Marshaler" || m.Type != Nil || m.Fields != nil || m.Key != -1 || m.Elem != -1 {
+			t.Fatalf("unexpected Marshaler: %+v", m)
+		}
+	}
+	self := s.Types[root.Fields[9].Type]
+	for _, f := range self.Fields {
+		if f.Name == "S" && s.Types[f.Type].Name != "binny.S" {
+			t.Fatalf("the recursive field refers to %+v", s.Types[f.Type])
+		}
+	}
+
+	// the schema can be shipped with the data
+	b, err := Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out Schema
+	if err = Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*s, out) {
+		t.Fatalf("exp: %+v\ngot: %+v", *s, out)
+	}
+
+	if rs, err := SchemaOf(&rect{}); err != nil || rs.Types[0].Registered != "rect" || rs.Types[0].Name != "binny.rect" {
+		t.Fatalf("unexpected schema: %+v, %v", rs, err)
+	}
+	for _, v := range []interface{}{nil, make(chan int), struct{ F func() }{}, map[*int]int{}} {
+		if _, err = SchemaOf(v); !errors.Is(err, ErrUnsupportedType) {
+			t.Fatalf("%T: expected ErrUnsupportedType, got %v", v, err)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package binny
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// ErrNotStarted gets returned by End when there's no slice or map to end.
+var ErrNotStarted = errors.New("End without a matching BeginSlice or BeginMap")
+
+// BeginSlice starts a slice whose length doesn't have to be known up front, write its elements with Encode
+// or the Write methods then call End. It decodes exactly like a slice written by Encode.
+//
+//	[Slice][Nil][entry(elem0)]...[entry(elemN)][EOV]
+func (enc *Encoder) BeginSlice() error {
+	return enc.begin(Slice)
+}
+
+// BeginMap is BeginSlice for maps, write a key then its value for every entry.
+func (enc *Encoder) BeginMap() error {
+	return enc.begin(Map)
+}
+
+func (enc *Encoder) begin(t Type) error {
+	if err := enc.enter(reflect.Value{}); err != nil {
+		return err
+	}
+	enc.open++
+	enc.nested++ // references are shared by all the elements, like they are for a slice written by Encode
+	enc.writeType(t)
+	return enc.writeEntries(-1)
+}
+
+// End ends the slice or map started by the last BeginSlice or BeginMap.
+func (enc *Encoder) End() error {
+	if enc.open == 0 {
+		return ErrNotStarted
+	}
+	enc.open--
+	if enc.nested--; enc.nested == 0 && len(enc.refs) > 0 {
+		enc.refs = nil
+	}
+	enc.leave(reflect.Value{})
+	return enc.writeType(EOV)
+}
+
+// BeginSlice reads the start of a slice and returns its length, or -1 if it was written by Encoder.BeginSlice.
+// Read the elements with Decode or the Read methods while More returns true, then call End.
+// A Nil entry is read as an empty slice.
+func (dec *Decoder) BeginSlice() (int, error) {
+	return dec.begin(Slice, "slice length", dec.opts.MaxSliceLen)
+}
+
+// BeginMap is BeginSlice for maps, read a key then its value for every entry.
+func (dec *Decoder) BeginMap() (int, error) {
+	return dec.begin(Map, "map length", dec.opts.MaxMapLen)
+}
+
+func (dec *Decoder) begin(t Type, limit string, max int) (int, error) {
+	if isNil, err := dec.ReadNil(); err != nil || isNil {
+		if isNil {
+			dec.open = append(dec.open, Nil)
+		}
+		return 0, err
+	}
+	if err := dec.expectType(t); err != nil {
+		return 0, err
+	}
+	ln, err := dec.readLen(limit, max)
+	if err != nil {
+		return 0, err
+	}
+	if err = dec.enter(); err != nil {
+		return 0, err
+	}
+	dec.open = append(dec.open, t)
+	dec.nested++
+	return ln, nil
+}
+
+// More reports whether the slice or map started by the last BeginSlice or BeginMap has more entries.
+func (dec *Decoder) More() bool {
+	if n := len(dec.open); n == 0 || dec.open[n-1] == Nil {
+		return false
+	}
+	ft, err := dec.PeekType()
+	return err == nil && ft != EOV
+}
+
+// End reads the end of the slice or map started by the last BeginSlice or BeginMap,
+// all of its entries must have been read.
+func (dec *Decoder) End() error {
+	n := len(dec.open)
+	if n == 0 {
+		return ErrNotStarted
+	}
+	t := dec.open[n-1]
+	dec.open = dec.open[:n-1]
+	if t == Nil {
+		return nil
+	}
+	if dec.nested--; dec.nested == 0 && len(dec.refs) > 0 {
+		dec.refs = nil
+	}
+	dec.leave()
+	return dec.expectType(EOV)
+}
+
+// readLen reads the length of a Map or Slice, -1 means it wasn't known when it was written
+// and the entries go on until the EOV.
+func (dec *Decoder) readLen(limit string, max int) (int, error) {
+	var (
+		ln  uint64
+		err error
+	)
+	if dec.compact {
+		if ln, err = dec.readUvarint(); err != nil || ln == 0 {
+			return -1, err
+		}
+		ln--
+	} else {
+		if isNil, err := dec.ReadNil(); err != nil || isNil {
+			return -1, err
+		}
+		if ln, _, err = dec.ReadUint(); err != nil {
+			return 0, err
+		}
+	}
+	if err = dec.checkLen(limit, max, ln); err != nil {
+		return 0, err
+	}
+	if ln > math.MaxInt {
+		return 0, fmt.Errorf("invalid length: %d", ln)
+	}
+	return int(ln), nil
+}
+
+// readSize reads the length of a String, ByteSlice, Binary, Gob or Symbol.
+func (dec *Decoder) readSize() (uint64, error) {
+	if dec.compact {
+		return dec.readUvarint()
+	}
+	ln, _, err := dec.ReadUint()
+	return ln, err
+}
+
+// hasEntry reports whether there's an entry i in a Map or Slice of length ln, which can be -1 (see readLen).
+func (dec *Decoder) hasEntry(i, ln int, limit string, max int) (bool, error) {
+	if ln >= 0 {
+		return i < ln, nil
+	}
+	ft, err := dec.PeekType()
+	if err != nil || ft == EOV {
+		return false, err
+	}
+	return true, dec.checkLen(limit, max, uint64(i+1))
+}
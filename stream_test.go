@@ -0,0 +1,206 @@
+package binny
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStreamSlice(t *testing.T) {
+	in := []S{{I8: 1}, {Str: "two"}, {U64: 3}}
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	if err := enc.BeginSlice(); err != nil {
+		t.Fatal(err)
+	}
+	for i := range in {
+		if err := enc.Encode(&in[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.End(); err != nil {
+		t.Fatal(err)
+	}
+	enc.Flush()
+	b := buf.Bytes()
+
+	var out []S
+	if err := Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("exp: %+v\ngot: %+v", in, out)
+	}
+
+	var arr [2]S
+	if err := Unmarshal(b, &arr); err == nil {
+		t.Fatal("expected an error decoding 3 elements into an array of 2")
+	}
+	if b, _ := Marshal([]int{1, 2, 3, 4, 5}); Unmarshal(b, &[2]int{}) == nil {
+		t.Fatal("expected an error decoding 5 elements into an array of 2")
+	}
+
+	var generic interface{}
+	if err := Unmarshal(b, &generic); err != nil {
+		t.Fatal(err)
+	}
+	if s, ok := generic.([]interface{}); !ok || len(s) != 3 {
+		t.Fatalf("unexpected value: %#v", generic)
+	}
+
+	dec := NewBytesDecoder(append(b, byte(BoolTrue)))
+	if err := dec.Skip(); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := dec.ReadBool(); err != nil || !v {
+		t.Fatalf("expected the value after the skipped slice, got %v, %v", v, err)
+	}
+
+	dec = NewBytesDecoderOptions(b, DecoderOptions{MaxSliceLen: 2})
+	var le *LimitError
+	if err := dec.Decode(&out); !errors.As(err, &le) {
+		t.Fatalf("expected a LimitError, got %v", err)
+	}
+}
+
+func TestStreamMap(t *testing.T) {
+	enc := NewBytesEncoder(nil)
+	enc.BeginMap()
+	for i, k := range []string{"a", "b", "c"} {
+		enc.Encode(k)
+		enc.Encode(i)
+	}
+	if err := enc.End(); err != nil {
+		t.Fatal(err)
+	}
+
+	var out map[string]int
+	if err := Unmarshal(enc.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if exp := map[string]int{"a": 0, "b": 1, "c": 2}; !reflect.DeepEqual(exp, out) {
+		t.Fatalf("exp: %v\ngot: %v", exp, out)
+	}
+
+	var generic interface{}
+	if err := Unmarshal(enc.Bytes(), &generic); err != nil {
+		t.Fatal(err)
+	}
+	if m, ok := generic.(map[string]interface{}); !ok || len(m) != 3 || m["c"] != int64(2) {
+		t.Fatalf("unexpected value: %#v", generic)
+	}
+
+	dec := NewBytesDecoder(enc.Bytes())
+	ln, err := dec.BeginMap()
+	if err != nil || ln != -1 {
+		t.Fatalf("expected an unknown length, got %v, %v", ln, err)
+	}
+	got := map[string]int{}
+	for dec.More() {
+		var (
+			k string
+			v int
+		)
+		if err = dec.Decode(&k); err != nil {
+			t.Fatal(err)
+		}
+		if err = dec.Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+		got[k] = v
+	}
+	if err = dec.End(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, got) {
+		t.Fatalf("exp: %v\ngot: %v", out, got)
+	}
+}
+
+func TestStreamDecoder(t *testing.T) {
+	b, _ := Marshal(struct {
+		A []int
+		B []int
+	}{A: []int{1, 2, 3}})
+	b = append(b, byte(Nil))
+
+	dec := NewBytesDecoder(b)
+	if err := dec.End(); err != ErrNotStarted {
+		t.Fatalf("expected ErrNotStarted, got %v", err)
+	}
+	if n, err := dec.BeginSlice(); err == nil {
+		t.Fatalf("expected an error starting a slice on a struct, got %d", n)
+	}
+
+	dec = NewBytesDecoder(b)
+	dec.readType()
+	if name, err := dec.readName(); err != nil || name != "A" {
+		t.Fatal(name, err)
+	}
+	ln, err := dec.BeginSlice()
+	if err != nil || ln != 3 {
+		t.Fatalf("expected a length of 3, got %v, %v", ln, err)
+	}
+	var got []int
+	for dec.More() {
+		var v int
+		if err = dec.Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, v)
+	}
+	if err = dec.End(); err != nil || len(got) != 3 {
+		t.Fatalf("unexpected result: %v, %v", got, err)
+	}
+	if err = dec.expectType(EOV); err != nil {
+		t.Fatal(err)
+	}
+
+	// a Nil entry is an empty slice
+	if ln, err = dec.BeginSlice(); err != nil || ln != 0 || dec.More() {
+		t.Fatalf("expected an empty slice, got %v, %v", ln, err)
+	}
+	if err = dec.End(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStreamReferences(t *testing.T) {
+	shared := &refNode{Name: "shared"}
+	enc := NewBytesEncoderOptions(nil, EncoderOptions{References: true})
+	enc.BeginSlice()
+	enc.Encode(shared)
+	enc.Encode(shared)
+	enc.End()
+	if err := enc.End(); err != ErrNotStarted {
+		t.Fatalf("expected ErrNotStarted, got %v", err)
+	}
+
+	var out []*refNode
+	if err := Unmarshal(enc.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 || out[0] != out[1] || out[0].Name != "shared" {
+		t.Fatalf("the elements aren't shared: %+v", out)
+	}
+}
+
+func TestHugeLength(t *testing.T) {
+	in := append([]byte{byte(Slice)}, Exp(Len(math.MaxUint64)).b...)
+	opts := DecoderOptions{MaxSliceLen: -1}
+	for _, v := range []interface{}{new([]int), new([2]int), new(interface{})} {
+		if err := NewBytesDecoderOptions(in, opts).Decode(v); err == nil || !strings.Contains(err.Error(), "invalid length") {
+			t.Fatalf("%T: expected an invalid length error, got %v", v, err)
+		}
+	}
+	if err := NewBytesDecoderOptions(in, opts).Skip(); err == nil {
+		t.Fatal("expected an error")
+	}
+	var le *LimitError
+	if err := NewBytesDecoder(in).Skip(); !errors.As(err, &le) || le.Limit != "slice length" {
+		t.Fatalf("expected a LimitError, got %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+package binny
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// EncodeStruct writes a struct exactly like the reflection encoder does, it's meant for generated code (see cmd/binnygen).
+// v must be a pointer to the struct being encoded and is only used to detect cycles,
+// names are the struct's fields in order, set is a bitmap of the fields to write (bit i%8 of set[i/8] is field i),
+// it must be (len(names)+7)/8 bytes long. fn gets called to write the value of every field in set.
+func (enc *Encoder) EncodeStruct(v interface{}, names []string, set []byte, fn func(i int) error) (err error) {
+	if len(names) == 0 {
+		return enc.writeType(EmptyStruct)
+	}
+	rv := reflect.ValueOf(v)
+	if err = enc.enter(rv); err != nil {
+		return
+	}
+	defer enc.leave(rv)
+	packed := enc.opts.PackStructs
+	if packed {
+		enc.writeType(PackedStruct)
+		enc.writeVarUint(uint64(len(names)))
+		for _, b := range set {
+			enc.writeByte(b)
+		}
+	} else {
+		enc.writeType(Struct)
+	}
+	for i, name := range names {
+		if set[i/8]&(1<<(i%8)) == 0 {
+			continue
+		}
+		if !packed {
+			enc.writeName(name)
+		}
+		if err = fn(i); err != nil {
+			return
+		}
+	}
+	if !packed {
+		err = enc.writeType(EOV)
+	}
+	return
+}
+
+// DecodeStruct reads a struct written by EncodeStruct or the reflection encoder, it's meant for generated code (see cmd/binnygen).
+// v must be a pointer to the struct being decoded and is only used for errors, names are the struct's fields in order,
+// and fn gets called to read the value of every field found in the input.
+func (dec *Decoder) DecodeStruct(v interface{}, names []string, fn func(i int) error) error {
+	switch ft, err := dec.PeekType(); {
+	case err != nil:
+		return err
+	case ft == Nil || ft == EmptyStruct:
+		_, err = dec.readType()
+		return err
+	case ft == PackedStruct:
+		dec.readType()
+		return dec.decodePackedStruct(v, names, fn)
+	}
+	if err := dec.expectType(Struct); err != nil {
+		return err
+	}
+	if err := dec.enter(); err != nil {
+		return err
+	}
+	defer dec.leave()
+	for next := 0; ; {
+		if dec.peekType() == EOV {
+			_, err := dec.readType()
+			return err
+		}
+		n, err := dec.readName()
+		if err != nil {
+			return err
+		}
+		i := fieldIndex(names, n, next)
+		if i == -1 {
+			if dec.opts.DisallowUnknownFields {
+				return &UnknownFieldError{reflect.TypeOf(v).Elem(), n}
+			}
+			if err = dec.Skip(); err != nil {
+				return err
+			}
+			continue
+		}
+		if err = fn(i); err != nil {
+			return wrapPathError(dec, err, "."+names[i])
+		}
+		next = i + 1
+	}
+}
+
+func (dec *Decoder) decodePackedStruct(v interface{}, names []string, fn func(i int) error) error {
+	bitmap, err := dec.readPackedBitmap()
+	if err != nil {
+		return err
+	}
+	if bitmap.n != len(names) {
+		return fmt.Errorf("packed %v has %d fields, expected %d", reflect.TypeOf(v).Elem(), bitmap.n, len(names))
+	}
+	if err = dec.enter(); err != nil {
+		return err
+	}
+	defer dec.leave()
+	for i := range names {
+		if !bitmap.has(i) {
+			continue
+		}
+		if err = fn(i); err != nil {
+			return wrapPathError(dec, err, "."+names[i])
+		}
+	}
+	return nil
+}
+
+// fieldIndex returns the index of name in names, or -1.
+// fields are usually written in order, so it checks names[next] first.
+func fieldIndex(names []string, name string, next int) int {
+	if next < len(names) && names[next] == name {
+		return next
+	}
+	for i, n := range names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,274 @@
+// Package table stores binny values under string keys in a single file that can be read by key
+// without scanning it: the values are followed by an index sorted by key, found through a fixed-size footer.
+//
+//	table  = [value]...[index][footer]
+//	index  = binny-encoded []entry{Key, Offset, Length, CRC-32C of the value}
+//	footer = [uint64(offset of index)][uint64(len(index))][uint32(crc32c(index))][0x8b 'T' 'B' 'L']
+//
+// Numbers in the footer are little-endian and every value is a standalone binny stream.
+package table
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/missionMeteora/binny.v2"
+)
+
+const footerSize = 24
+
+var (
+	magic = [4]byte{0x8b, 'T', 'B', 'L'}
+
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+	// the index is read all at once and always by this package, so it uses the smallest format
+	indexOptions = binny.EncoderOptions{Header: true, PackStructs: true, Compact: true}
+)
+
+var (
+	// ErrNotFound gets returned by Reader.Get for a key that isn't in the table.
+	ErrNotFound = errors.New("key not found")
+
+	// ErrDuplicateKey gets returned by Writer.Add for a key that was already added.
+	ErrDuplicateKey = errors.New("duplicate key")
+
+	// ErrClosed gets returned by Writer.Add after Close.
+	ErrClosed = errors.New("table writer is closed")
+
+	// ErrChecksum gets returned when a value or the index doesn't match its checksum.
+	ErrChecksum = errors.New("checksum mismatch")
+)
+
+type entry struct {
+	Key    string
+	Offset uint64
+	Length uint64
+	CRC    uint32
+}
+
+// Writer writes a table to an io.Writer, values are written as they're added and the index on Close.
+type Writer struct {
+	w     io.Writer
+	enc   *binny.Encoder
+	off   uint64
+	index []entry
+	keys  map[string]struct{}
+	err   error // sticky write or index error
+}
+
+// NewWriter is an alias for NewWriterOptions(w, binny.EncoderOptions{})
+func NewWriter(w io.Writer) *Writer {
+	return NewWriterOptions(w, binny.EncoderOptions{})
+}
+
+// NewWriterOptions returns a new Writer that encodes values with the specified options,
+// every value gets its own encoder state, so interned names and references don't span values.
+// Options that change the format, like Compact, have to be matched by the Reader's DecoderOptions unless Header is set.
+func NewWriterOptions(w io.Writer, opts binny.EncoderOptions) *Writer {
+	return &Writer{
+		w:    w,
+		enc:  binny.NewBytesEncoderOptions(make([]byte, 0, binny.DefaultEncoderBufferSize), opts),
+		keys: map[string]struct{}{},
+	}
+}
+
+// Add writes v under key, which must not have been added already.
+func (w *Writer) Add(key string, v interface{}) error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.keys == nil {
+		return ErrClosed
+	}
+	if _, ok := w.keys[key]; ok {
+		return fmt.Errorf("%w: %q", ErrDuplicateKey, key)
+	}
+	w.enc.ResetBytes(w.enc.Bytes()[:0])
+	if err := w.enc.Encode(v); err != nil {
+		return err
+	}
+	b := w.enc.Bytes()
+	if err := w.write(b); err != nil {
+		return err
+	}
+	w.keys[key] = struct{}{}
+	w.index = append(w.index, entry{key, w.off - uint64(len(b)), uint64(len(b)), crc32.Checksum(b, crcTable)})
+	return nil
+}
+
+// Close writes the index and the footer, it doesn't close the underlying io.Writer.
+func (w *Writer) Close() error {
+	if w.err != nil || w.keys == nil {
+		return w.err
+	}
+	w.keys = nil
+	sort.Slice(w.index, func(i, j int) bool { return w.index[i].Key < w.index[j].Key })
+
+	enc := binny.NewBytesEncoderOptions(w.enc.Bytes()[:0], indexOptions)
+	if err := enc.Encode(w.index); err != nil {
+		w.err = err // so a second Close doesn't look like it worked
+		return err
+	}
+	index := enc.Bytes()
+	b := binary.LittleEndian.AppendUint64(index, w.off)
+	b = binary.LittleEndian.AppendUint64(b, uint64(len(index)))
+	b = binary.LittleEndian.AppendUint32(b, crc32.Checksum(index, crcTable))
+	b = append(b, magic[:]...)
+	return w.write(b)
+}
+
+func (w *Writer) write(b []byte) error {
+	n, err := w.w.Write(b)
+	w.off += uint64(n)
+	if err != nil {
+		w.err = err
+	}
+	return err
+}
+
+// Reader reads values from a table by key, it's safe for concurrent use.
+type Reader struct {
+	r     io.ReaderAt
+	opts  binny.DecoderOptions
+	index []entry
+
+	closer io.Closer
+}
+
+// NewReader is an alias for NewReaderOptions(r, size, binny.DecoderOptions{})
+func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+	return NewReaderOptions(r, size, binny.DecoderOptions{})
+}
+
+// NewReaderOptions reads the index of the table of the given size in r, values are decoded with the specified options.
+func NewReaderOptions(r io.ReaderAt, size int64, opts binny.DecoderOptions) (*Reader, error) {
+	if size < footerSize {
+		return nil, errors.New("not a table: too short")
+	}
+	var footer [footerSize]byte
+	if err := readAt(r, footer[:], size-footerSize); err != nil {
+		return nil, err
+	}
+	if [4]byte(footer[20:]) != magic {
+		return nil, errors.New("not a table: bad magic")
+	}
+	off, ln := binary.LittleEndian.Uint64(footer[:]), binary.LittleEndian.Uint64(footer[8:])
+	if off > uint64(size-footerSize) || ln != uint64(size-footerSize)-off {
+		return nil, fmt.Errorf("invalid index (offset %d, %d bytes) in a table of %d bytes", off, ln, size)
+	}
+	b := make([]byte, ln)
+	if err := readAt(r, b, int64(off)); err != nil {
+		return nil, err
+	}
+	if crc32.Checksum(b, crcTable) != binary.LittleEndian.Uint32(footer[16:]) {
+		return nil, fmt.Errorf("index: %w", ErrChecksum)
+	}
+	tr := &Reader{r: r, opts: opts}
+	if err := binny.NewBytesDecoderOptions(b, binny.DecoderOptions{RequireHeader: true}).Decode(&tr.index); err != nil {
+		return nil, fmt.Errorf("index: %w", err)
+	}
+	for i, e := range tr.index {
+		if e.Offset+e.Length > off || e.Offset+e.Length < e.Offset || i > 0 && tr.index[i-1].Key >= e.Key {
+			return nil, fmt.Errorf("index: invalid entry for %q", e.Key)
+		}
+	}
+	return tr, nil
+}
+
+// Open is an alias for OpenOptions(name, binny.DecoderOptions{})
+func Open(name string) (*Reader, error) {
+	return OpenOptions(name, binny.DecoderOptions{})
+}
+
+// OpenOptions opens the named table file like NewReaderOptions, call Close when done.
+func OpenOptions(name string, opts binny.DecoderOptions) (*Reader, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	tr, err := NewReaderOptions(f, fi.Size(), opts)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	tr.closer = f
+	return tr, nil
+}
+
+// Close closes the file if the Reader was created with Open.
+func (tr *Reader) Close() error {
+	if tr.closer == nil {
+		return nil
+	}
+	return tr.closer.Close()
+}
+
+// Len returns the number of values in the table.
+func (tr *Reader) Len() int {
+	return len(tr.index)
+}
+
+// Key returns the i-th key in sorted order, i must be in [0, Len()).
+func (tr *Reader) Key(i int) string {
+	return tr.index[i].Key
+}
+
+// Has reports whether the table has a value under key.
+func (tr *Reader) Has(key string) bool {
+	_, ok := tr.find(key)
+	return ok
+}
+
+// Get decodes the value under key into v, it returns ErrNotFound if there isn't one.
+func (tr *Reader) Get(key string, v interface{}) error {
+	b, err := tr.Raw(key)
+	if err != nil {
+		return err
+	}
+	return binny.NewBytesDecoderOptions(b, tr.opts).Decode(v)
+}
+
+// Raw returns the encoded value under key, it returns ErrNotFound if there isn't one.
+func (tr *Reader) Raw(key string) ([]byte, error) {
+	i, ok := tr.find(key)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	e := tr.index[i]
+	b := make([]byte, e.Length)
+	if err := readAt(tr.r, b, int64(e.Offset)); err != nil {
+		return nil, err
+	}
+	if crc32.Checksum(b, crcTable) != e.CRC {
+		return nil, fmt.Errorf("%q: %w", key, ErrChecksum)
+	}
+	return b, nil
+}
+
+func (tr *Reader) find(key string) (int, bool) {
+	i := sort.Search(len(tr.index), func(i int) bool { return tr.index[i].Key >= key })
+	return i, i < len(tr.index) && tr.index[i].Key == key
+}
+
+// readAt fills b from r at off, io.ReaderAt can return io.EOF along with the last bytes of its input.
+func readAt(r io.ReaderAt, b []byte, off int64) error {
+	n, err := r.ReadAt(b, off)
+	if n == len(b) {
+		return nil
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,141 @@
+package table
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/missionMeteora/binny.v2"
+)
+
+type value struct {
+	N    int
+	Name string
+}
+
+func writeTable(t *testing.T, n int, opts binny.EncoderOptions) []byte {
+	var buf bytes.Buffer
+	w := NewWriterOptions(&buf, opts)
+	// out of order on purpose
+	for i := n - 1; i >= 0; i-- {
+		if err := w.Add("key"+strconv.Itoa(i), &value{i, "v" + strconv.Itoa(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Add("key1", 1); !errors.Is(err, ErrDuplicateKey) {
+		t.Fatalf("expected ErrDuplicateKey, got %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Add("late", 1); err != ErrClosed {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestTable(t *testing.T) {
+	b := writeTable(t, 100, binny.EncoderOptions{Compact: true})
+	tr, err := NewReaderOptions(bytes.NewReader(b), int64(len(b)), binny.DecoderOptions{Compact: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.Len() != 100 {
+		t.Fatalf("expected 100 keys, got %d", tr.Len())
+	}
+	for i := 1; i < tr.Len(); i++ {
+		if tr.Key(i-1) >= tr.Key(i) {
+			t.Fatalf("the keys aren't sorted: %q >= %q", tr.Key(i-1), tr.Key(i))
+		}
+	}
+	for _, i := range []int{0, 42, 99} {
+		var v value
+		if err = tr.Get("key"+strconv.Itoa(i), &v); err != nil {
+			t.Fatal(err)
+		}
+		if v.N != i || v.Name != "v"+strconv.Itoa(i) {
+			t.Fatalf("unexpected value for %d: %+v", i, v)
+		}
+	}
+	var v value
+	if err = tr.Get("nope", &v); err != ErrNotFound || tr.Has("nope") || !tr.Has("key7") {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	// a corrupt value only breaks that value
+	c := append([]byte(nil), b...)
+	c[3] ^= 1
+	if tr, err = NewReaderOptions(bytes.NewReader(c), int64(len(c)), binny.DecoderOptions{Compact: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.Get("key99", &v); !errors.Is(err, ErrChecksum) {
+		t.Fatalf("expected ErrChecksum, got %v", err)
+	}
+	if err = tr.Get("key98", &v); err != nil || v.N != 98 {
+		t.Fatalf("unexpected value: %+v, %v", v, err)
+	}
+
+	for _, c := range [][]byte{nil, b[:len(b)-1], b[footerSize:], append([]byte{0}, b...)} {
+		if _, err = NewReader(bytes.NewReader(c), int64(len(c))); err == nil {
+			t.Fatalf("expected an error reading a broken table of %d bytes", len(c))
+		}
+	}
+	c = append([]byte(nil), b...)
+	c[len(c)-footerSize-1] ^= 1
+	if _, err = NewReader(bytes.NewReader(c), int64(len(c))); !errors.Is(err, ErrChecksum) {
+		t.Fatalf("expected ErrChecksum, got %v", err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "table")
+	if err := os.WriteFile(name, writeTable(t, 10, binny.EncoderOptions{}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tr, err := Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Close()
+	var v value
+	if err = tr.Get("key3", &v); err != nil || v.N != 3 {
+		t.Fatalf("unexpected value: %+v, %v", v, err)
+	}
+
+	var buf bytes.Buffer
+	if err = NewWriter(&buf).Close(); err != nil {
+		t.Fatal(err)
+	}
+	if tr, err = NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len())); err != nil || tr.Len() != 0 {
+		t.Fatalf("unexpected empty table: %v", err)
+	}
+}
+
+type failingWriter struct{ n int }
+
+var errFull = errors.New("disk full")
+
+func (fw *failingWriter) Write(p []byte) (int, error) {
+	if fw.n -= len(p); fw.n < 0 {
+		return 0, errFull
+	}
+	return len(p), nil
+}
+
+func TestCloseError(t *testing.T) {
+	w := NewWriter(&failingWriter{n: 20})
+	if err := w.Add("key", "value"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := w.Close(); err != errFull {
+			t.Fatalf("%d: expected errFull, got %v", i, err)
+		}
+	}
+	if err := w.Add("late", 1); err != errFull {
+		t.Fatalf("expected errFull, got %v", err)
+	}
+}
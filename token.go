@@ -0,0 +1,252 @@
+package binny
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Token holds a value of one of these types:
+//
+//	StructStart, FieldName, PackedStructStart, FieldIndex, MapStart, SliceStart, End,
+//	InterfaceName, RefID, BackRefID,
+//	nil, bool, struct{}, int64, uint64, float32, float64, complex64, complex128,
+//	string, []byte, BinaryData, GobData
+//
+// A struct is a StructStart followed by FieldName and value pairs then an End, a packed struct is
+// a PackedStructStart followed by FieldIndex and value pairs then an End, maps and slices are a MapStart
+// or SliceStart followed by their entries then an End. InterfaceName and RefID come right before the value they apply to.
+type Token interface{}
+
+type (
+	StructStart struct{}
+	FieldName   string
+
+	// PackedStructStart starts a struct written with EncoderOptions.PackStructs,
+	// bit i%8 of Set[i/8] is set if field i is present.
+	PackedStructStart struct {
+		Fields int
+		Set    []byte
+	}
+	FieldIndex int
+
+	// MapStart and SliceStart have a Len of -1 if they were written by Encoder.BeginMap or Encoder.BeginSlice.
+	MapStart   struct{ Len int }
+	SliceStart struct{ Len int }
+
+	// End ends the last struct, packed struct, map or slice.
+	End struct{}
+
+	InterfaceName string // the name of a registered type, see Register
+	RefID         int    // the next value can be referred to by a BackRefID, see EncoderOptions.References
+	BackRefID     int    // the value that followed the RefID with the same id
+
+	BinaryData []byte // the output of encoding.BinaryMarshaler
+	GobData    []byte // the output of gob.GobEncoder
+)
+
+var errUnexpectedEnd = errors.New("End without a matching StructStart, PackedStructStart, MapStart or SliceStart")
+
+// tokenFrame is a struct, packed struct, map or slice being read by Decoder.Token.
+type tokenFrame struct {
+	t      Type
+	value  bool // a FieldName or FieldIndex was returned, the field's value is next
+	bitmap packedBitmap
+	next   int // the next packed field to look at
+}
+
+// Token returns the next token in the input stream, at the end of the stream it returns nil, io.EOF.
+// The value after a FieldName or FieldIndex can be read with Decode instead.
+func (dec *Decoder) Token() (Token, error) {
+	if n := len(dec.toks); n > 0 {
+		f := &dec.toks[n-1]
+		switch f.t {
+		case Struct:
+			if f.value {
+				break
+			}
+			ft, err := dec.PeekType()
+			if err != nil {
+				return nil, err
+			}
+			if ft == EOV {
+				dec.readType()
+				return dec.endToken()
+			}
+			name, err := dec.readName()
+			if err != nil {
+				return nil, err
+			}
+			f.value = true
+			return FieldName(name), nil
+		case PackedStruct:
+			if f.value {
+				break
+			}
+			for f.next < f.bitmap.n && !f.bitmap.has(f.next) {
+				f.next++
+			}
+			if f.next == f.bitmap.n {
+				return dec.endToken()
+			}
+			f.value = true
+			f.next++
+			return FieldIndex(f.next - 1), nil
+		default:
+			ft, err := dec.PeekType()
+			if err != nil {
+				return nil, err
+			}
+			if ft == EOV {
+				dec.readType()
+				return dec.endToken()
+			}
+		}
+	}
+	return dec.valueToken()
+}
+
+func (dec *Decoder) valueToken() (Token, error) {
+	ft, err := dec.PeekType()
+	if err != nil {
+		return nil, err
+	}
+	if len(dec.toks) == 0 && !dec.tokPrefix && dec.nested == 0 {
+		dec.refs = nil // a new top-level value, like a new call to Decode
+	}
+	// these come before a value instead of being one
+	dec.tokPrefix = ft == Interface || ft == Ref
+	switch ft {
+	case Interface:
+		dec.readType()
+		name, err := dec.readName()
+		return InterfaceName(name), err
+	case Ref:
+		dec.readType()
+		dec.refs = append(dec.refs, reflect.Value{})
+		return RefID(len(dec.refs) - 1), nil
+	}
+
+	dec.tokenValueRead()
+	switch ft {
+	case Struct:
+		dec.readType()
+		return StructStart{}, dec.pushToken(tokenFrame{t: Struct})
+	case PackedStruct:
+		dec.readType()
+		pb, err := dec.readPackedBitmap()
+		if err != nil {
+			return nil, err
+		}
+		return PackedStructStart{pb.n, pb.b}, dec.pushToken(tokenFrame{t: PackedStruct, bitmap: pb})
+	case Map:
+		dec.readType()
+		ln, err := dec.readLen("map length", dec.opts.MaxMapLen)
+		if err != nil {
+			return nil, err
+		}
+		return MapStart{ln}, dec.pushToken(tokenFrame{t: Map})
+	case Slice:
+		dec.readType()
+		ln, err := dec.readLen("slice length", dec.opts.MaxSliceLen)
+		if err != nil {
+			return nil, err
+		}
+		return SliceStart{ln}, dec.pushToken(tokenFrame{t: Slice})
+	case BackRef:
+		dec.readType()
+		id, err := dec.readUvarint()
+		return BackRefID(id), err
+	case Binary:
+		b, err := dec.readBytes(Binary)
+		return BinaryData(b), err
+	case Gob:
+		b, err := dec.readBytes(Gob)
+		return GobData(b), err
+	}
+	return dec.readValue()
+}
+
+func (dec *Decoder) pushToken(f tokenFrame) error {
+	if err := dec.enter(); err != nil {
+		return err
+	}
+	dec.toks = append(dec.toks, f)
+	return nil
+}
+
+func (dec *Decoder) endToken() (Token, error) {
+	dec.toks = dec.toks[:len(dec.toks)-1]
+	dec.leave()
+	return End{}, nil
+}
+
+// tokenValueRead marks the value of the current struct field as read.
+func (dec *Decoder) tokenValueRead() {
+	if n := len(dec.toks); n > 0 {
+		dec.toks[n-1].value = false
+	}
+}
+
+// WriteToken writes a token returned by Decoder.Token, so a stream can be transformed without decoding it.
+// Any other value gets written with Encode.
+func (enc *Encoder) WriteToken(tok Token) error {
+	switch tok := tok.(type) {
+	case nil:
+		return enc.writeType(Nil)
+	case StructStart:
+		enc.toks = append(enc.toks, Struct)
+		return enc.writeType(Struct)
+	case FieldName:
+		return enc.writeName(string(tok))
+	case PackedStructStart:
+		if len(tok.Set) != (tok.Fields+7)/8 {
+			return fmt.Errorf("packed struct with %d fields needs a %d bytes bitmap, got %d", tok.Fields, (tok.Fields+7)/8, len(tok.Set))
+		}
+		enc.toks = append(enc.toks, PackedStruct)
+		enc.writeType(PackedStruct)
+		enc.writeVarUint(uint64(tok.Fields))
+		_, err := enc.Write(tok.Set)
+		return err
+	case FieldIndex:
+		return nil // implied by the bitmap
+	case MapStart:
+		enc.toks = append(enc.toks, Map)
+		enc.writeType(Map)
+		return enc.writeEntries(tok.Len)
+	case SliceStart:
+		enc.toks = append(enc.toks, Slice)
+		enc.writeType(Slice)
+		return enc.writeEntries(tok.Len)
+	case End:
+		n := len(enc.toks)
+		if n == 0 {
+			return errUnexpectedEnd
+		}
+		t := enc.toks[n-1]
+		enc.toks = enc.toks[:n-1]
+		if t == PackedStruct {
+			return nil
+		}
+		return enc.writeType(EOV)
+	case InterfaceName:
+		enc.writeType(Interface)
+		return enc.writeName(string(tok))
+	case RefID:
+		return enc.writeType(Ref) // ids are implied by the order
+	case BackRefID:
+		enc.writeType(BackRef)
+		return enc.writeVarUint(uint64(tok))
+	case BinaryData:
+		enc.writeType(Binary)
+		enc.writeLen(len(tok))
+		_, err := enc.Write(tok)
+		return err
+	case GobData:
+		enc.writeType(Gob)
+		enc.writeLen(len(tok))
+		_, err := enc.Write(tok)
+		return err
+	}
+	return enc.Encode(tok)
+}
@@ -0,0 +1,128 @@
+package binny
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestTokenRoundTrip(t *testing.T) {
+	shared := &refNode{Name: "shared"}
+	vals := []interface{}{
+		&benchVal,
+		SAll{I: -1, C64: 1, C128: 2, BS: []byte("x"), M: map[string]*SAll{"x": {U: 5}}, M2: map[MapKey]struct{}{{1, 2, 3}: {}}},
+		sM{{1, 2}: 3},
+		[]shape{circle{1}, &rect{2, 3}},
+		timeNow,
+		bigIntVal,
+		struct{}{},
+		[]interface{}{nil, true, false, -5.5, float32(1), int64(-1 << 40), "str"},
+		&refNode{Name: "root", Children: []*refNode{shared, shared}, Any: shared},
+	}
+	for _, opts := range []EncoderOptions{
+		{},
+		{Header: true, PackStructs: true},
+		{InternFieldNames: true},
+		{References: true},
+		{Header: true, References: true, PackStructs: true, InternFieldNames: true},
+		{Header: true, Compact: true},
+	} {
+		enc := NewBytesEncoderOptions(nil, opts)
+		for _, v := range vals {
+			if err := enc.Encode(v); err != nil {
+				t.Fatal(err)
+			}
+		}
+		enc.BeginSlice()
+		enc.Encode(shared)
+		enc.Encode(shared)
+		enc.End()
+		in := enc.Bytes()
+
+		dec := NewBytesDecoder(in)
+		out := NewBytesEncoderOptions(nil, opts)
+		for {
+			tok, err := dec.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%+v: %v", opts, err)
+			}
+			if err = out.WriteToken(tok); err != nil {
+				t.Fatalf("%+v: %v", opts, err)
+			}
+		}
+		if !bytes.Equal(in, out.Bytes()) {
+			t.Fatalf("%+v: the tokens didn't write the same bytes\nexp: %v\ngot: %v", opts, in, out.Bytes())
+		}
+	}
+}
+
+func TestToken(t *testing.T) {
+	type small struct {
+		A int
+		B []string
+		M map[string]uint
+	}
+	b, err := Marshal(&small{A: -1, B: []string{"x"}, M: map[string]uint{"k": 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []Token{
+		StructStart{},
+		FieldName("A"), int64(-1),
+		FieldName("B"), SliceStart{1}, "x", End{},
+		FieldName("M"), MapStart{1}, "k", uint64(2), End{},
+		End{},
+	}
+	dec := NewBytesDecoder(b)
+	var got []Token
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, tok)
+	}
+	if !reflect.DeepEqual(exp, got) {
+		t.Fatalf("exp: %#v\ngot: %#v", exp, got)
+	}
+
+	// field values can be decoded in the middle of the tokens
+	dec = NewBytesDecoder(b)
+	var names []FieldName
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := tok.(End); ok {
+			break
+		}
+		if name, ok := tok.(FieldName); ok {
+			names = append(names, name)
+			var v interface{}
+			if err = dec.Decode(&v); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if exp := []FieldName{"A", "B", "M"}; !reflect.DeepEqual(exp, names) {
+		t.Fatalf("exp: %v\ngot: %v", exp, names)
+	}
+	if _, err = dec.Token(); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+
+	if err = NewBytesEncoder(nil).WriteToken(End{}); err != errUnexpectedEnd {
+		t.Fatalf("expected errUnexpectedEnd, got %v", err)
+	}
+	if err = NewBytesEncoder(nil).WriteToken(PackedStructStart{Fields: 9, Set: []byte{1}}); err == nil {
+		t.Fatal("expected an error writing a short bitmap")
+	}
+}
@@ -4,9 +4,9 @@ package binny
 
 import "fmt"
 
-const _Type_name = "NilBoolTrueBoolFalseEmptyStructVarIntInt8Int16Int32Int64VarUintUint8Uint16Uint32Uint64Float32Float64Complex64Complex128StringByteSliceStructMapSliceInterfaceBinaryGob"
+const _Type_name = "NilBoolTrueBoolFalseEmptyStructVarIntInt8Int16Int32Int64VarUintUint8Uint16Uint32Uint64Float32Float64Complex64Complex128StringByteSliceStructMapSliceInterfaceBinaryGobSymbolSymbolRefPackedStructRefBackRef"
 
-var _Type_index = [...]uint8{0, 3, 11, 20, 31, 37, 41, 46, 51, 56, 63, 68, 74, 80, 86, 93, 100, 109, 119, 125, 134, 140, 143, 148, 157, 163, 166}
+var _Type_index = [...]uint8{0, 3, 11, 20, 31, 37, 41, 46, 51, 56, 63, 68, 74, 80, 86, 93, 100, 109, 119, 125, 134, 140, 143, 148, 157, 163, 166, 172, 181, 193, 196, 203}
 
 func (i Type) String() string {
 	if i == EOV {
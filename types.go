@@ -9,6 +9,7 @@ import (
 	"errors"
 	"reflect"
 	"sort"
+	"strings"
 	"sync"
 )
 
@@ -29,33 +30,38 @@ var (
 type Type byte
 
 const (
-	Nil         Type   = iota // nil/empty type
-	BoolTrue                  // true
-	BoolFalse                 // false
-	EmptyStruct               // struct{}
-	VarInt                    // Varint 1-10 bytes
-	Int8                      //
-	Int16                     //
-	Int32                     //
-	Int64                     //
-	VarUint                   // VarUint 1-10 bytes
-	Uint8                     //
-	Uint16                    //
-	Uint32                    //
-	Uint64                    //
-	Float32                   //
-	Float64                   //
-	Complex64                 //
-	Complex128                //
-	String                    //
-	ByteSlice                 // []byte
-	Struct                    //
-	Map                       //
-	Slice                     // or array
-	Interface                 // interface{}
-	Binary                    // encoding BinaryMarshaler/BinaryUnmarshaler
-	Gob                       // encoding/gob GobEncoder/GobDecoder
-	EOV         = ^Nil        // end-of-value, *any* new types must be added before this line.
+	Nil          Type   = iota // nil/empty type
+	BoolTrue                   // true
+	BoolFalse                  // false
+	EmptyStruct                // struct{}
+	VarInt                     // Varint 1-10 bytes
+	Int8                       //
+	Int16                      //
+	Int32                      //
+	Int64                      //
+	VarUint                    // VarUint 1-10 bytes
+	Uint8                      //
+	Uint16                     //
+	Uint32                     //
+	Uint64                     //
+	Float32                    //
+	Float64                    //
+	Complex64                  //
+	Complex128                 //
+	String                     //
+	ByteSlice                  // []byte
+	Struct                     //
+	Map                        //
+	Slice                      // or array
+	Interface                  // interface{}
+	Binary                     // encoding BinaryMarshaler/BinaryUnmarshaler
+	Gob                        // encoding/gob GobEncoder/GobDecoder
+	Symbol                     // a string that gets added to the stream's symbol table, used for interned names
+	SymbolRef                  // varuint index into the stream's symbol table
+	PackedStruct               // struct with its fields written by position, see EncoderOptions.PackStructs
+	Ref                        // a pointed-to value that later entries can refer to, see EncoderOptions.References
+	BackRef                    // varuint index of an earlier Ref in the same value
+	EOV          = ^Nil        // end-of-value, *any* new types must be added before this line.
 )
 
 func isFieldType(t Type, o ...Type) bool {
@@ -83,6 +89,11 @@ type field struct {
 	enc    encoderFunc
 	dec    decoderFunc
 	typ    reflect.Type
+	ptr    reflect.Type // the unnamed pointer type typeFields followed to get typ, if any
+
+	omitEmpty bool // never write the zero value, even with EncoderOptions.KeepZeroFields
+	keepZero  bool // always write the zero value
+	quoted    bool // written as a string, see the string tag option
 }
 
 func cachedTypeFields(t reflect.Type) []field {
@@ -157,7 +168,7 @@ func typeFields(t reflect.Type) []field {
 				if sf.PkgPath != "" && !sf.Anonymous { // unexported
 					continue
 				}
-				name, ignore, tagged := getTagValues(sf)
+				name, opts, ignore, tagged := getTagValues(sf)
 				if ignore {
 					continue
 				}
@@ -165,14 +176,15 @@ func typeFields(t reflect.Type) []field {
 				copy(index, f.index)
 				index[len(f.index)] = i
 
-				ft := sf.Type
+				ft, pt := sf.Type, reflect.Type(nil)
 				if ft.Name() == "" && ft.Kind() == reflect.Ptr {
 					// Follow pointer.
-					ft = ft.Elem()
+					ft, pt = ft.Elem(), ft
 				}
 
 				// Record found field and index sequence.
-				if name != "" || !sf.Anonymous || ft.Kind() != reflect.Struct {
+				inline := opts.inline || sf.Anonymous && name == ""
+				if !inline || ft.Kind() != reflect.Struct {
 					if name == "" {
 						name = sf.Name
 					}
@@ -180,14 +192,23 @@ func typeFields(t reflect.Type) []field {
 					if zeroFn == nil {
 						zeroFn = isZero
 					}
+					enc, dec := typeEncoder(ft), typeDecoder(ft)
+					quoted := opts.asString && isQuotable(ft.Kind())
+					if quoted {
+						enc, dec, pt = quotedEncoder, quotedDecoder, nil
+					}
 					fields = append(fields, field{
-						name:   name,
-						index:  index,
-						typ:    ft,
-						tagged: tagged,
-						enc:    typeEncoder(ft),
-						dec:    typeDecoder(ft),
-						zero:   zeroFn,
+						name:      name,
+						index:     index,
+						typ:       ft,
+						ptr:       pt,
+						tagged:    tagged,
+						enc:       enc,
+						dec:       dec,
+						zero:      zeroFn,
+						omitEmpty: opts.omitEmpty,
+						keepZero:  opts.keepZero,
+						quoted:    quoted,
 					})
 					if count[f.typ] > 1 {
 						// If there were multiple instances, add a second,
@@ -275,6 +296,36 @@ func dominantField(fields []field) (field, bool) {
 	return fields[0], true
 }
 
+// value returns the value of the field in struct v, ok is false if the field shouldn't be written,
+// because it's zero and not kept or a nil embedded pointer is in the way.
+// Nil values that should be written are returned as an invalid reflect.Value.
+func (f *field) value(v reflect.Value, keepZero bool) (fv reflect.Value, ok bool) {
+	if fv = fieldByIndex(v, f.index, false); !fv.IsValid() {
+		return fv, false
+	}
+	keep := f.keepZero || keepZero && !f.omitEmpty
+	if f.typ.Kind() != reflect.Interface { // keep the interface so registered types get their name written
+		if fv = indirect(fv); !fv.IsValid() {
+			return fv, keep
+		}
+	}
+	if !f.zero(fv) {
+		return fv, true
+	}
+	if !keep {
+		return fv, false
+	}
+	switch fv.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Interface:
+		if fv.IsNil() {
+			return reflect.Value{}, true
+		}
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return fv, false
+	}
+	return fv, true
+}
+
 func fieldByIndex(v reflect.Value, index []int, setNew bool) reflect.Value {
 	for _, i := range index {
 		if v.Kind() == reflect.Ptr {
@@ -341,18 +392,51 @@ func (x byIndex) Less(i, j int) bool {
 	return len(x[i].index) < len(x[j].index)
 }
 
-func getTagValues(sf reflect.StructField) (name string, ignore, tagged bool) {
+// tagOptions are the options after the name in a binny tag, e.g. `binny:"name,omitempty"`.
+type tagOptions struct {
+	omitEmpty bool // omitempty: never write the zero value
+	keepZero  bool // keepzero or alwaysemit: always write the zero value
+	inline    bool // inline: write the fields of a struct field as if it was embedded
+	asString  bool // string: write numbers and bools as strings
+}
+
+func parseTagOptions(s string) (opts tagOptions) {
+	for s != "" {
+		var o string
+		if i := strings.IndexByte(s, ','); i > -1 {
+			o, s = s[:i], s[i+1:]
+		} else {
+			o, s = s, ""
+		}
+		switch o {
+		case "omitempty":
+			opts.omitEmpty = true
+		case "keepzero", "alwaysemit":
+			opts.keepZero = true
+		case "inline":
+			opts.inline = true
+		case "string":
+			opts.asString = true
+		}
+	}
+	return
+}
+
+func getTagValues(sf reflect.StructField) (name string, opts tagOptions, ignore, tagged bool) {
 	v := sf.Tag.Get("binny")
 	if v == "-" {
-		return "", true, true
+		return "", opts, true, true
+	}
+	if i := strings.IndexByte(v, ','); i > -1 {
+		v, opts = v[:i], parseTagOptions(v[i+1:])
 	}
 	if len(v) > 0 {
-		return v, false, true
+		return v, opts, false, true
 	}
-	if sf.Anonymous {
-		return "", false, false
+	if sf.Anonymous || opts.inline {
+		return "", opts, false, false
 	}
-	return sf.Name, false, false
+	return sf.Name, opts, false, false
 }
 
 func indirect(v reflect.Value) reflect.Value {
@@ -404,6 +488,17 @@ func isZero(v reflect.Value) bool {
 	panic(v.Kind().String()) // if this triggers then it's a bug
 }
 
+// isQuotable reports whether the string tag option applies to values of kind k.
+func isQuotable(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Bool:
+		return true
+	}
+	return false
+}
+
 func isNative(k reflect.Kind, supportStruct bool) bool {
 	switch k {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
@@ -12,7 +12,6 @@ import (
 	"runtime"
 	"strings"
 	"testing"
-	"unsafe"
 
 	"encoding/binary"
 	"encoding/gob"
@@ -210,11 +209,11 @@ func autoUint(u uint64, ln bool) (v []byte) {
 	case u <= math.MaxUint8:
 		v = []byte{byte(Uint8), byte(u)}
 	case u <= math.MaxUint16:
-		v = append([]byte{byte(Uint16)}, (*[2]byte)(unsafe.Pointer(&u))[:2:2]...)
+		v = le.AppendUint16([]byte{byte(Uint16)}, uint16(u))
 	case u <= math.MaxUint32:
-		v = append([]byte{byte(Uint32)}, (*[4]byte)(unsafe.Pointer(&u))[:4:4]...)
+		v = le.AppendUint32([]byte{byte(Uint32)}, uint32(u))
 	default:
-		v = append([]byte{byte(Uint64)}, (*[8]byte)(unsafe.Pointer(&u))[:8:8]...)
+		v = le.AppendUint64([]byte{byte(Uint64)}, u)
 	}
 	if ln {
 		return v
@@ -224,20 +223,16 @@ func autoUint(u uint64, ln bool) (v []byte) {
 }
 
 func autoInt(v int64) []byte {
-	u := v
-	if u < 0 {
-		u = -u
+	if v >= math.MinInt8 && v <= math.MaxInt8 {
+		return []byte{byte(v)}
 	}
-	if u <= math.MaxInt8 {
-		return []byte{byte(u)}
+	if v >= math.MinInt16 && v <= math.MaxInt16 {
+		return le.AppendUint16(nil, uint16(v))
 	}
-	if u <= math.MaxInt16 {
-		return (*[8]byte)(unsafe.Pointer(&v))[:2:2]
+	if v >= math.MinInt32 && v <= math.MaxInt32 {
+		return le.AppendUint32(nil, uint32(v))
 	}
-	if u <= math.MaxInt32 {
-		return (*[8]byte)(unsafe.Pointer(&v))[:4:4]
-	}
-	return (*[8]byte)(unsafe.Pointer(&v))[:8:8]
+	return le.AppendUint64(nil, uint64(v))
 }
 
 var SLen = len(cachedTypeFields(reflect.TypeOf(S{})))
@@ -430,3 +425,83 @@ func TestMortalKombat(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestTagOptions(t *testing.T) {
+	type point struct {
+		X, Y int
+	}
+	type tagged struct {
+		Omit  int  `binny:"omit,omitempty"`
+		Keep  int  `binny:"keep,keepzero"`
+		Emit  bool `binny:",alwaysemit"`
+		Def   string
+		Ints  []int
+		Map   map[string]int
+		Ptr   *point
+		Pt    point    `binny:"pt,inline"`
+		Num   int16    `binny:",string"`
+		Float float32  `binny:",string"`
+		Ok    bool     `binny:",string"`
+		Names []string `binny:",string"` // not applicable
+	}
+
+	in := tagged{Num: -7, Float: 1.5, Ok: true, Names: []string{"a"}}
+	b, err := Marshal(&in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v interface{}
+	if err = Unmarshal(b, &v); err != nil {
+		t.Fatal(err)
+	}
+	m, _ := v.(map[string]interface{})
+	exp := map[string]interface{}{
+		"keep": int64(0), "Emit": false,
+		"Num": "-7", "Float": "1.5", "Ok": "true", "Names": []interface{}{"a"},
+	}
+	if !reflect.DeepEqual(m, exp) {
+		t.Fatalf("exp: %v\ngot: %v", exp, m)
+	}
+
+	full := tagged{1, 2, true, "def", []int{1}, map[string]int{"a": 1}, &point{1, 2}, point{3, 4}, 5, 6, true, []string{"b"}}
+	out := full
+	if err = Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Keep != 0 || out.Emit || out.Omit != 1 || out.Def != "def" || out.Num != -7 || out.Float != 1.5 || !out.Ok {
+		t.Fatalf("unexpected value: %+v", out)
+	}
+
+	var buf bytes.Buffer
+	enc := NewEncoderOptions(&buf, EncoderOptions{KeepZeroFields: true})
+	if err = enc.Encode(&tagged{Omit: 0, Pt: point{X: 3}}); err != nil {
+		t.Fatal(err)
+	}
+	enc.Flush()
+	if err = Unmarshal(buf.Bytes(), &v); err != nil {
+		t.Fatal(err)
+	}
+	m, _ = v.(map[string]interface{})
+	if _, ok := m["omit"]; ok || len(m) != 12 || m["Ints"] != nil || m["X"] != int64(3) || m["Y"] != int64(0) {
+		t.Fatalf("unexpected value: %v", m)
+	}
+
+	out = full
+	if err = Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	exp2 := tagged{Omit: 1, Pt: point{X: 3}, Num: 0, Names: nil}
+	if !reflect.DeepEqual(out, exp2) {
+		t.Fatalf("exp: %+v\ngot: %+v", exp2, out)
+	}
+
+	bad := struct {
+		Num string
+	}{"nope"}
+	if b, err = Marshal(&bad); err != nil {
+		t.Fatal(err)
+	}
+	if err = Unmarshal(b, &out); err == nil {
+		t.Fatal("expected an error for an invalid string number")
+	}
+}